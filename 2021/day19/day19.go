@@ -8,94 +8,122 @@ https://opensource.org/licenses/MIT.
 // was really slow and nondeterministically got the wrong answer.  The Go
 // implementation also nondeterministically got the wrong answer because
 // iteration order through a map isn't consistent and I incorrectly believed
-// that rotation was a commutative operation.
+// that rotation was a commutative operation.  Rotations are now rotation
+// matrices, so each aligned Pointset knows the true orientation of its scanner.
+// Alignment is now deterministic: points are visited in sorted order and
+// scanners are aligned in a breadth-first spanning tree from scanner 0, with
+// candidate pairs checked in parallel.  Run with
+// go run day19.go runner.go -v input.example.txt input.actual.txt
+// Two-dimensional inputs with x,y points are also supported; use -min-overlap
+// to set the number of shared beacons, e.g. 3 for the puzzle's 2D example.
 package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
+	"math"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
-	"time"
+	"sync"
 )
 
-type Direction int
+// Rotation is a 3x3 integer matrix for one of the 24 proper rotations of a
+// cube, i.e. signed permutation matrices with determinant 1.  Rotations are
+// applied to column vectors, so a.compose(b) applies b first, then a.
+type Rotation [3][3]int
 
-const (
-	Deosil      Direction = 1  // clockwise
-	Widdershins Direction = -1 // not actually used, but makes math more robust
-)
-
-type Rotation struct{ x, y, z int }
+var identity = Rotation{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
 
-var (
-	face1    = Rotation{}
-	face2    = Rotation{y: 1}
-	face3    = Rotation{y: 2}
-	face4    = Rotation{y: 3}
-	face5    = Rotation{z: 1}
-	face6    = Rotation{z: 3}
-	allFaces = []Rotation{face1, face2, face3, face4, face5, face6}
-	xTurns   = []Rotation{Rotation{}, Rotation{x: 1}, Rotation{x: 2}, Rotation{x: 3}}
-)
+// allRotations are the 24 proper rotations, identity first.
+var allRotations = func() []Rotation {
+	res := make([]Rotation, 0, 24)
+	perms := [][3]int{{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0}}
+	for _, perm := range perms {
+		for signs := 0; signs < 8; signs++ {
+			var r Rotation
+			for row, col := range perm {
+				r[row][col] = 1
+				if signs&(1<<row) != 0 {
+					r[row][col] = -1
+				}
+			}
+			if r.determinant() == 1 {
+				res = append(res, r)
+			}
+		}
+	}
+	if len(res) != 24 || res[0] != identity {
+		log.Fatalf("Expected 24 rotations starting with identity, got %d: %v", len(res), res)
+	}
+	return res
+}()
 
-func (r Rotation) String() string {
-	return fmt.Sprintf("Rotation{x:%d,y:%d,z:%d}", r.x, r.y, r.z)
-}
+// planarRotations are the 4 rotations which keep points in the z=0 plane,
+// for two-dimensional inputs.
+var planarRotations = func() []Rotation {
+	res := make([]Rotation, 0, 4)
+	for _, r := range allRotations {
+		if r[2][2] == 1 {
+			res = append(res, r)
+		}
+	}
+	return res
+}()
 
-func (r Rotation) union(o Rotation) Rotation {
-	return Rotation{r.x + o.x, r.y + o.y, r.z + o.z}
+func (r Rotation) determinant() int {
+	return r[0][0]*(r[1][1]*r[2][2]-r[1][2]*r[2][1]) -
+		r[0][1]*(r[1][0]*r[2][2]-r[1][2]*r[2][0]) +
+		r[0][2]*(r[1][0]*r[2][1]-r[1][1]*r[2][0])
 }
 
-type Point struct{ x, y, z int }
-
-func (p Point) rotate(axis string, dir Direction) Point {
-	switch axis {
-	case "x":
-		return Point{x: p.x, y: p.z * int(dir), z: -p.y * int(dir)}
-	case "y":
-		return Point{x: -p.z * int(dir), y: p.y, z: p.x * int(dir)}
-	case "z":
-		return Point{x: p.y * int(dir), y: -p.x * int(dir), z: p.z}
-	default:
-		log.Fatalf("Unknown axis %s", axis)
-		return p
+// compose returns the rotation which applies o and then r.
+func (r Rotation) compose(o Rotation) Rotation {
+	var res Rotation
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				res[i][j] += r[i][k] * o[k][j]
+			}
+		}
 	}
+	return res
 }
 
-func (p Point) rotateMultiple(axes Rotation) Point {
-	q := p
-	if axes.x != 0 {
-		dir := Deosil
-		if axes.x < 0 {
-			dir = Widdershins
-		}
-		for i := 0; i < int(dir)*axes.x; i++ {
-			q = q.rotate("x", dir)
-		}
-	}
-	if axes.y != 0 {
-		dir := Deosil
-		if axes.y < 0 {
-			dir = Widdershins
-		}
-		for i := 0; i < int(dir)*axes.y; i++ {
-			q = q.rotate("y", dir)
+// inverse returns the rotation which undoes r, which for a rotation matrix is
+// its transpose.
+func (r Rotation) inverse() Rotation {
+	var res Rotation
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			res[i][j] = r[j][i]
 		}
 	}
-	if axes.z != 0 {
-		dir := Deosil
-		if axes.z < 0 {
-			dir = Widdershins
-		}
-		for i := 0; i < int(dir)*axes.z; i++ {
-			q = q.rotate("z", dir)
-		}
+	return res
+}
+
+func (r Rotation) apply(p Point) Point {
+	return Point{
+		x: r[0][0]*p.x + r[0][1]*p.y + r[0][2]*p.z,
+		y: r[1][0]*p.x + r[1][1]*p.y + r[1][2]*p.z,
+		z: r[2][0]*p.x + r[2][1]*p.y + r[2][2]*p.z,
 	}
-	return q
 }
 
+func (r Rotation) String() string {
+	return fmt.Sprintf("Rotation%v", [3][3]int(r))
+}
+
+type Point struct{ x, y, z int }
+
 func (p Point) plus(o Point) Point {
 	return Point{x: p.x + o.x, y: p.y + o.y, z: p.z + o.z}
 }
@@ -104,34 +132,62 @@ func (p Point) minus(o Point) Point {
 	return Point{x: p.x - o.x, y: p.y - o.y, z: p.z - o.z}
 }
 
+func (p Point) less(o Point) bool {
+	if p.x != o.x {
+		return p.x < o.x
+	}
+	if p.y != o.y {
+		return p.y < o.y
+	}
+	return p.z < o.z
+}
+
 func (p Point) String() string {
 	return fmt.Sprintf("%d,%d,%d", p.x, p.y, p.z)
 }
 
+// Pointset is a set of beacons in some frame of reference.  The pose of the
+// scanner which saw them is rotation followed by moving to origin, so a
+// point p as reported by the scanner is at rotation.apply(p).plus(origin).
 type Pointset struct {
 	points   map[Point]bool
 	origin   Point
 	rotation Rotation
 }
 
-func (s *Pointset) rotate(axes Rotation) *Pointset {
+func newPointset() *Pointset {
+	return &Pointset{points: make(map[Point]bool), rotation: identity}
+}
+
+func (s *Pointset) rotate(r Rotation) *Pointset {
 	res := make(map[Point]bool)
 	for p := range s.points {
-		res[p.rotateMultiple(axes)] = true
+		res[r.apply(p)] = true
 	}
-	return &Pointset{points: res, origin: s.origin.rotateMultiple(axes), rotation: s.rotation.union(axes)}
+	return &Pointset{points: res, origin: r.apply(s.origin), rotation: r.compose(s.rotation)}
 }
 
-func (s *Pointset) allOrientations() []*Pointset {
-	res := make([]*Pointset, 0, 24)
-	for _, f := range allFaces {
-		for _, x := range xTurns {
-			res = append(res, s.rotate(f.union(x)))
-		}
+// allOrientations returns the set rotated by each of rotations.
+func (s *Pointset) allOrientations(rotations []Rotation) []*Pointset {
+	res := make([]*Pointset, 0, len(rotations))
+	for _, r := range rotations {
+		res = append(res, s.rotate(r))
 	}
 	return res
 }
 
+// transform converts a point from the scanner's frame of reference to this
+// set's frame.
+func (s *Pointset) transform(p Point) Point {
+	return s.rotation.apply(p).plus(s.origin)
+}
+
+// untransform converts a point from this set's frame of reference to the
+// scanner's frame.
+func (s *Pointset) untransform(p Point) Point {
+	return s.rotation.inverse().apply(p.minus(s.origin))
+}
+
 func (s *Pointset) offset(p Point) *Pointset {
 	res := make(map[Point]bool)
 	for q := range s.points {
@@ -140,9 +196,13 @@ func (s *Pointset) offset(p Point) *Pointset {
 	return &Pointset{points: res, origin: s.origin.plus(p), rotation: s.rotation}
 }
 
+// minOverlap is the number of beacons two scanners need to have in common to
+// be considered overlapping.
+var minOverlap = flag.Int("min-overlap", 12, "number of beacons scanners must share to overlap")
+
 func (s *Pointset) overlap(o *Pointset) *Pointset {
-	for p := range s.points {
-		for q := range o.points {
+	for _, p := range s.sorted() {
+		for _, q := range o.sorted() {
 			t := o.offset(p.minus(q))
 			matches := 0
 			for r := range t.points {
@@ -150,7 +210,7 @@ func (s *Pointset) overlap(o *Pointset) *Pointset {
 					matches++
 				}
 			}
-			if matches >= 12 {
+			if matches >= *minOverlap {
 				return t
 			}
 		}
@@ -158,6 +218,16 @@ func (s *Pointset) overlap(o *Pointset) *Pointset {
 	return nil
 }
 
+// sorted returns the points in a consistent order.
+func (s *Pointset) sorted() []Point {
+	res := make([]Point, 0, len(s.points))
+	for p := range s.points {
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].less(res[j]) })
+	return res
+}
+
 func (s *Pointset) String() string {
 	points := make([]string, 0, len(s.points))
 	for p := range s.points {
@@ -170,8 +240,110 @@ type Device struct {
 	name         string
 	pointset     *Pointset
 	orientations []*Pointset
+	// distances counts the squared distances between each pair of points.
+	distances map[int]int
+	// fingerprints count the squared distances from each point to the others;
+	// two neighbors can be the same distance away.
+	fingerprints map[Point]map[int]int
+}
+
+func squaredDistance(p, q Point) int {
+	d := p.minus(q)
+	return d.x*d.x + d.y*d.y + d.z*d.z
+}
+
+// computeFingerprints fills in distances and fingerprints, which don't change
+// when the scanner is rotated or moved.
+func (d *Device) computeFingerprints() {
+	d.distances = make(map[int]int)
+	d.fingerprints = make(map[Point]map[int]int)
+	for p := range d.pointset.points {
+		d.fingerprints[p] = make(map[int]int)
+	}
+	for p := range d.pointset.points {
+		for q := range d.pointset.points {
+			if p == q {
+				continue
+			}
+			dist := squaredDistance(p, q)
+			d.fingerprints[p][dist]++
+			if p.less(q) {
+				d.distances[dist]++
+			}
+		}
+	}
+}
+
+// sharedDistances counts the pairwise distances two devices have in common.
+func (d *Device) sharedDistances(o *Device) int {
+	res := 0
+	for dist, n := range d.distances {
+		res += min(n, o.distances[dist])
+	}
+	return res
+}
+
+// anchors returns pairs of points, one from each device, which have enough
+// distances to other points in common that they might be the same beacon.
+func (d *Device) anchors(o *Device) [][2]Point {
+	res := make([][2]Point, 0)
+	for _, p := range d.pointset.sorted() {
+		pf := d.fingerprints[p]
+		for _, q := range o.pointset.sorted() {
+			qf := o.fingerprints[q]
+			shared := 0
+			for dist, n := range pf {
+				shared += min(n, qf[dist])
+			}
+			if shared >= *minOverlap-1 {
+				res = append(res, [2]Point{p, q})
+			}
+		}
+	}
+	return res
+}
+
+// alignBrute finds an orientation and offset of d which overlaps source by
+// trying every pair of points in every orientation.
+func alignBrute(source *Pointset, _, d *Device) *Pointset {
+	for _, orient := range d.orientations {
+		if o := source.overlap(orient); o != nil {
+			return o
+		}
+	}
+	return nil
+}
+
+// alignFingerprint finds an orientation and offset of d which overlaps source,
+// where source is the aligned Pointset of device from.  Scanners which share
+// fewer than minOverlap choose 2 pairwise distances can't overlap, and within
+// overlapping scanners only points with similar distances to their neighbors
+// are tried as anchors.  A single anchor pair is enough if the orientation and
+// offset it implies lines up minOverlap beacons.
+func alignFingerprint(source *Pointset, from, d *Device) *Pointset {
+	if from.sharedDistances(d) < *minOverlap*(*minOverlap-1)/2 {
+		return nil
+	}
+	anchors := from.anchors(d)
+	for _, orient := range d.orientations {
+		for _, a := range anchors {
+			t := source.transform(a[0]).minus(orient.rotation.apply(a[1]))
+			matches := 0
+			for p := range orient.points {
+				if source.points[p.plus(t)] {
+					matches++
+				}
+			}
+			if matches >= *minOverlap {
+				return orient.offset(t)
+			}
+		}
+	}
+	return nil
 }
 
+var alignStrategy = flag.String("align", "fingerprint", "scanner alignment strategy: brute or fingerprint")
+
 func (d *Device) String() string {
 	points := make([]string, 0, len(d.pointset.points))
 	for p := range d.pointset.points {
@@ -180,61 +352,205 @@ func (d *Device) String() string {
 	return fmt.Sprintf("%s\n%s", d.name, strings.Join(points, "\n"))
 }
 
-func align(devices []*Device) map[int]*Pointset {
+// alignPair finds the position of device d relative to source, the aligned
+// Pointset of device from, using the -align strategy.
+func alignPair(source *Pointset, from, d *Device) *Pointset {
+	switch *alignStrategy {
+	case "brute":
+		return alignBrute(source, from, d)
+	case "fingerprint":
+		return alignFingerprint(source, from, d)
+	default:
+		log.Fatalf("Unknown alignment strategy %q", *alignStrategy)
+		return nil
+	}
+}
+
+var (
+	numWorkers = flag.Int("workers", runtime.NumCPU(), "number of goroutines checking scanner pairs")
+	selfCheck  = flag.Bool("self-check", false, "check that parallel alignment matches serial alignment")
+)
+
+// align positions every device relative to device 0.  Devices are aligned in
+// breadth-first order: each newly aligned device is checked against all
+// unaligned devices in index order, with checks spread across workers
+// goroutines.  The results don't depend on the number of workers.
+func align(devices []*Device, workers int) Alignment {
 	found := make(map[int]*Pointset)
 	found[0] = devices[0].pointset
-	checked := make(map[int]map[int]bool)
-	remaining := len(devices) - 1
-	for remaining > 0 {
-		thisloop := 0
-	eachdevice:
+	order := []int{0}
+	parent := map[int]int{0: -1}
+	queue := []int{0}
+	for len(queue) > 0 {
+		j := queue[0]
+		queue = queue[1:]
+		candidates := make([]int, 0, len(devices))
 		for i := range devices {
-			if checked[i] == nil {
-				checked[i] = make(map[int]bool)
+			if found[i] == nil {
+				candidates = append(candidates, i)
 			}
-			if found[i] != nil {
-				continue
+		}
+		results := make([]*Pointset, len(candidates))
+		work := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < min(workers, len(candidates)); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := range work {
+					results[k] = alignPair(found[j], devices[j], devices[candidates[k]])
+				}
+			}()
+		}
+		for k := range candidates {
+			work <- k
+		}
+		close(work)
+		wg.Wait()
+		for k, i := range candidates {
+			if results[k] != nil {
+				found[i] = results[k]
+				parent[i] = j
+				order = append(order, i)
+				queue = append(queue, i)
+			}
+		}
+	}
+	if len(found) != len(devices) {
+		log.Fatalf("Couldn't align %d of %d scanners", len(devices)-len(found), len(devices))
+	}
+	for i, s := range found {
+		for p := range devices[i].pointset.points {
+			if q := s.transform(p); !s.points[q] || s.untransform(q) != p {
+				log.Fatalf("%s pose %s %s maps %s to %s, not an aligned point", devices[i].name, s.rotation, s.origin, p, q)
+			}
+		}
+	}
+	return Alignment{found: found, order: order, parent: parent}
+}
+
+// Alignment is the result of aligning all devices.
+type Alignment struct {
+	// found has each device's points and pose relative to device 0.
+	found map[int]*Pointset
+	// order lists devices in the order they were aligned.
+	order []int
+	// parent is the device each device was aligned against, -1 for device 0.
+	parent map[int]int
+}
+
+// alignChecked runs align with -workers goroutines and, with -self-check,
+// exits if a serial alignment gives a different result.
+func alignChecked(devices []*Device) Alignment {
+	res := align(devices, *numWorkers)
+	if *selfCheck {
+		serial := align(devices, 1)
+		for i, s := range serial.found {
+			p := res.found[i]
+			if p.origin != s.origin || p.rotation != s.rotation || !maps.Equal(p.points, s.points) {
+				log.Fatalf("%s parallel alignment %s %s differs from serial %s %s", devices[i].name, p.rotation, p.origin, s.rotation, s.origin)
+			}
+		}
+		log.Printf("Parallel alignment with %d workers matches serial alignment", *numWorkers)
+	}
+	return res
+}
+
+// parseDevices reads sections of input separated by blank lines.  Each
+// section starts with a "--- scanner N ---" header followed by one beacon per
+// line as x,y,z coordinates, or x,y for a two-dimensional puzzle.
+func parseDevices(lines []string) []*Device {
+	devices := make([]*Device, 0)
+	dims := 0
+	for start := 0; start < len(lines); {
+		end := start
+		for end < len(lines) && lines[end] != "" {
+			end++
+		}
+		if end > start {
+			section := lines[start:end]
+			if !strings.HasPrefix(section[0], "--- scanner") {
+				log.Fatalf("Expected scanner header on line %d, got %q", start+1, section[0])
 			}
-			for j := range devices {
-				if i == j || found[j] == nil || checked[i][j] {
-					continue
+			dev := &Device{name: strings.Trim(section[0], "- "), pointset: newPointset()}
+			for i, line := range section[1:] {
+				coords := strings.Split(line, ",")
+				if dims == 0 {
+					dims = len(coords)
+				}
+				if len(coords) != dims || dims < 2 || dims > 3 {
+					log.Fatalf("Expected %d coordinates on line %d, got %q", dims, start+i+2, line)
 				}
-				checked[i][j] = true
-				source := found[j]
-				for _, orient := range devices[i].orientations {
-					o := source.overlap(orient)
-					if o == nil {
-						continue
+				var c [3]int
+				for j, v := range coords {
+					n, err := strconv.Atoi(v)
+					if err != nil {
+						log.Fatalf("Could not parse %q on line %d: %v", line, start+i+2, err)
 					}
-					found[i] = o
-					remaining--
-					thisloop++
-					continue eachdevice
+					c[j] = n
 				}
+				dev.pointset.points[Point{x: c[0], y: c[1], z: c[2]}] = true
 			}
+			devices = append(devices, dev)
 		}
-		if thisloop == 0 {
-			log.Fatalf("Couldn't make any progress after %d matches\n%v", len(found), found)
-		}
+		start = end + 1
+	}
+	if len(devices) == 0 {
+		log.Fatalf("No scanners in input")
+	}
+	rotations := allRotations
+	if dims == 2 {
+		rotations = planarRotations
+	}
+	for _, d := range devices {
+		d.orientations = d.pointset.allOrientations(rotations)
+		d.computeFingerprints()
 	}
-	return found
+	return devices
+}
+
+// alignedInput is an input's parsed devices and their alignment.
+type alignedInput struct {
+	devices []*Device
+	a       Alignment
+}
+
+// alignments caches alignedInput by input contents so part2 reuses the
+// alignment from part1.
+var alignments = make(map[string]alignedInput)
+
+// alignInput parses and aligns the devices in lines, or returns the cached
+// result if the same input was already aligned.
+func alignInput(lines []string) ([]*Device, Alignment) {
+	key := strings.Join(lines, "\n")
+	if c, ok := alignments[key]; ok {
+		return c.devices, c.a
+	}
+	devices := parseDevices(lines)
+	a := alignChecked(devices)
+	alignments[key] = alignedInput{devices: devices, a: a}
+	return devices, a
 }
 
 // part1 counts the number of distinct points after aligning all scanner devices.
-func part1(devices []*Device) int {
-	found := align(devices)
+func part1(lines []string) string {
+	devices, a := alignInput(lines)
+	if *export != "" {
+		exportMap(devices, a)
+	}
 	all := make(map[Point]int)
-	for _, s := range found {
+	for _, s := range a.found {
 		for p := range s.points {
 			all[p]++
 		}
 	}
-	return len(all)
+	return strconv.Itoa(len(all))
 }
 
-// part1 counts the maximum Manhattan distance between two scanner devices.
-func part2(devices []*Device) int {
-	found := align(devices)
+// part2 counts the maximum Manhattan distance between two scanner devices.
+func part2(lines []string) string {
+	_, a := alignInput(lines)
+	found := a.found
 	max := 0
 	for _, ps1 := range found {
 		for _, ps2 := range found {
@@ -242,7 +558,184 @@ func part2(devices []*Device) int {
 			max = maxInt(max, absInt(p.x)+absInt(p.y)+absInt(p.z))
 		}
 	}
-	return max
+	return strconv.Itoa(max)
+}
+
+var export = flag.String("export", "", "comma-separated files to write the aligned beacon map to, format based on extension: .ply, .obj, or .json")
+
+// exportCount tracks export file names so multiple input files don't
+// overwrite each other.
+var exportCount = make(map[string]int)
+
+// mappedBeacon is a beacon position in device 0's frame of reference along
+// with the devices which saw it, in alignment order.
+type mappedBeacon struct {
+	pos    Point
+	seenBy []int
+}
+
+// beaconMap returns every distinct beacon, sorted by position.
+func beaconMap(a Alignment) []mappedBeacon {
+	seen := make(map[Point][]int)
+	for _, i := range a.order {
+		for p := range a.found[i].points {
+			seen[p] = append(seen[p], i)
+		}
+	}
+	res := make([]mappedBeacon, 0, len(seen))
+	for p, devs := range seen {
+		res = append(res, mappedBeacon{pos: p, seenBy: devs})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].pos.less(res[j].pos) })
+	return res
+}
+
+// deviceColor picks a distinct color for the nth device in alignment order by
+// spreading hues around the color wheel.
+func deviceColor(n, total int) [3]int {
+	h := float64(n) / float64(max(total, 1)) * 6
+	x := 1 - math.Abs(math.Mod(h, 2)-1)
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g = 1, x
+	case 1:
+		r, g = x, 1
+	case 2:
+		g, b = 1, x
+	case 3:
+		g, b = x, 1
+	case 4:
+		r, b = x, 1
+	default:
+		r, b = 1, x
+	}
+	return [3]int{int(r * 255), int(g * 255), int(b * 255)}
+}
+
+// scannerColor marks scanner origins in point clouds.
+var scannerColor = [3]int{255, 255, 255}
+
+// exportMap writes the aligned map to each file in the -export flag.
+func exportMap(devices []*Device, a Alignment) {
+	beacons := beaconMap(a)
+	rank := make(map[int]int)
+	for n, i := range a.order {
+		rank[i] = n
+	}
+	for _, fname := range strings.Split(*export, ",") {
+		ext := filepath.Ext(fname)
+		base := strings.TrimSuffix(fname, ext)
+		if n := exportCount[fname]; n > 0 {
+			base += fmt.Sprintf(".%d", n)
+		}
+		exportCount[fname]++
+		out, err := os.Create(base + ext)
+		if err != nil {
+			log.Fatalf("Could not create %s: %v", base+ext, err)
+		}
+		w := bufio.NewWriter(out)
+		switch ext {
+		case ".ply":
+			writePLY(w, a, beacons, rank)
+		case ".obj":
+			writeOBJ(w, devices, a, beacons, rank)
+		case ".json":
+			err = writeJSON(w, devices, a, beacons)
+		default:
+			log.Fatalf("Unknown export format %q for %s, want .ply, .obj, or .json", ext, fname)
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		if err == nil {
+			err = out.Close()
+		}
+		if err != nil {
+			log.Fatalf("Error writing %s: %v", base+ext, err)
+		}
+		log.Printf("Wrote %d beacons and %d scanners to %s", len(beacons), len(a.order), base+ext)
+	}
+}
+
+// writePLY writes an ASCII PLY point cloud.  Each vertex has a color and the
+// index of the device which discovered it; scanner origins are white and have
+// a scanner property of 1.
+func writePLY(w io.Writer, a Alignment, beacons []mappedBeacon, rank map[int]int) {
+	fmt.Fprintf(w, "ply\nformat ascii 1.0\ncomment Advent of Code 2021 day 19 beacon map\n")
+	fmt.Fprintf(w, "element vertex %d\n", len(beacons)+len(a.order))
+	fmt.Fprintf(w, "property int x\nproperty int y\nproperty int z\n")
+	fmt.Fprintf(w, "property uchar red\nproperty uchar green\nproperty uchar blue\n")
+	fmt.Fprintf(w, "property int device\nproperty uchar scanner\nend_header\n")
+	for _, b := range beacons {
+		c := deviceColor(rank[b.seenBy[0]], len(a.order))
+		fmt.Fprintf(w, "%d %d %d %d %d %d %d 0\n", b.pos.x, b.pos.y, b.pos.z, c[0], c[1], c[2], b.seenBy[0])
+	}
+	for _, i := range a.order {
+		o := a.found[i].origin
+		c := scannerColor
+		fmt.Fprintf(w, "%d %d %d %d %d %d %d 1\n", o.x, o.y, o.z, c[0], c[1], c[2], i)
+	}
+}
+
+// writeOBJ writes a Wavefront OBJ file with vertex colors, which MeshLab and
+// Blender understand.  Beacons and scanner origins are separate groups of
+// point elements.
+func writeOBJ(w io.Writer, devices []*Device, a Alignment, beacons []mappedBeacon, rank map[int]int) {
+	fmt.Fprintf(w, "# Advent of Code 2021 day 19 beacon map\n")
+	fmt.Fprintf(w, "g beacons\n")
+	for _, b := range beacons {
+		c := deviceColor(rank[b.seenBy[0]], len(a.order))
+		fmt.Fprintf(w, "v %d %d %d %.3f %.3f %.3f\n", b.pos.x, b.pos.y, b.pos.z, float64(c[0])/255, float64(c[1])/255, float64(c[2])/255)
+	}
+	for i := range beacons {
+		fmt.Fprintf(w, "p %d\n", i+1)
+	}
+	fmt.Fprintf(w, "g scanners\n")
+	for _, i := range a.order {
+		o := a.found[i].origin
+		fmt.Fprintf(w, "# %s\nv %d %d %d 1 1 1\n", devices[i].name, o.x, o.y, o.z)
+	}
+	for n := range a.order {
+		fmt.Fprintf(w, "p %d\n", len(beacons)+n+1)
+	}
+}
+
+// writeJSON writes scanner poses and beacon positions with the devices which
+// saw each beacon.
+func writeJSON(w io.Writer, devices []*Device, a Alignment, beacons []mappedBeacon) error {
+	type jsonScanner struct {
+		Index    int       `json:"index"`
+		Name     string    `json:"name"`
+		Origin   [3]int    `json:"origin"`
+		Rotation [3][3]int `json:"rotation"`
+		Parent   int       `json:"parent"`
+		Color    [3]int    `json:"color"`
+	}
+	type jsonBeacon struct {
+		Position [3]int `json:"position"`
+		Scanner  int    `json:"scanner"`
+		SeenBy   []int  `json:"seenBy"`
+	}
+	res := struct {
+		Scanners []jsonScanner `json:"scanners"`
+		Beacons  []jsonBeacon  `json:"beacons"`
+	}{}
+	for n, i := range a.order {
+		s := a.found[i]
+		res.Scanners = append(res.Scanners, jsonScanner{
+			Index: i, Name: devices[i].name, Origin: [3]int{s.origin.x, s.origin.y, s.origin.z},
+			Rotation: s.rotation, Parent: a.parent[i], Color: deviceColor(n, len(a.order)),
+		})
+	}
+	for _, b := range beacons {
+		res.Beacons = append(res.Beacons, jsonBeacon{
+			Position: [3]int{b.pos.x, b.pos.y, b.pos.z}, Scanner: b.seenBy[0], SeenBy: b.seenBy,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
 }
 
 func absInt(a int) int {
@@ -258,52 +751,8 @@ func maxInt(a, b int) int {
 	return a
 }
 
-func runFile(fname string) {
-	f, err := os.Open(fname)
-	if err != nil {
-		log.Fatalf("Error opening %s: %v", fname, err)
-	}
-	defer f.Close()
-	devices := make([]*Device, 0)
-	var dev *Device
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "scanner") {
-			dev = &Device{name: line, pointset: &Pointset{points: make(map[Point]bool)}}
-			devices = append(devices, dev)
-		} else if strings.ContainsRune(line, ',') {
-			var x, y, z int
-			if _, err := fmt.Sscanf(line, "%d,%d,%d", &x, &y, &z); err != nil {
-				log.Fatalf("Could not parse %s", line)
-				continue
-			}
-			dev.pointset.points[Point{x: x, y: y, z: z}] = true
-		}
-	}
-	for _, d := range devices {
-		d.orientations = d.pointset.allOrientations()
-	}
-	fmt.Printf("Running Part 1 on %s\n", fname)
-	start := time.Now()
-	res1 := part1(devices)
-	dur := time.Since(start)
-	fmt.Printf("Part 1 got %d\n", res1)
-	fmt.Printf("Part 1 took %s\n", dur)
-	fmt.Printf("Running Part 2 on %s\n", fname)
-	start = time.Now()
-	res2 := part2(devices)
-	dur = time.Since(start)
-	fmt.Printf("Part 2 got %d\n", res2)
-	fmt.Printf("Part 2 took %s\n", dur)
-}
-
 func main() {
-	for _, fname := range os.Args[1:] {
-		fmt.Printf("Running day19 on %s\n", fname)
-		start := time.Now()
-		runFile(fname)
-		dur := time.Since(start)
-		fmt.Printf("%s took %s\n", fname, dur)
-	}
+	runMain(part1, part2)
 }
+
+const dayName = "day19"
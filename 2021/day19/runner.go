@@ -0,0 +1 @@
+../../lang/go/runner.go
\ No newline at end of file
@@ -7,24 +7,45 @@
 // https://adventofcode.com/2021/day/23
 package main
 
-/* day23 computes the cost to move amphipods from four rooms into their proper
+/* day23 computes the cost to move amphipods from rooms into their proper
 rooms; moves are blocked if there's an amphipod in the way.  Amphipods have
 letter-based kinds.  Each space move costs A=1, B=10, C=100, D=1000.
 Go implementation because I had trouble finding a bug in my Raku solution,
-and each run took tens of minutes.  Board structure is hard-coded for
-example and actual input because I didn't want to focus on parsing; this
-turned out to be convenient since part 2 added new input. */
+and each run took tens of minutes.  Input is the burrow diagram; part 2
+unfolds it by inserting two rows from the puzzle description below the first
+row of each room.  Run with
+go run day23.go runner.go -v input.example.txt input.actual.txt
+
+The hallway width, the number and position of rooms, and room depths all come
+from the diagram, so variants with more rooms or a longer hallway also work.
+The leftmost room belongs to A, the next to B, and so on; each kind costs ten
+times as much as the previous one unless the diagram is followed by a line
+like "A=1 B=20 C=300" which sets per-kind move costs. */
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math/bits"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
-var printWinner = flag.Bool("print-winner", false, "Show winning moves")
+var (
+	printWinner = flag.Bool("print-winner", false, "Show winning moves")
+	replayMoves = flag.Bool("replay", false, "Animate winning moves in the terminal")
+	replayDelay = flag.Duration("replay-delay", 500*time.Millisecond, "Time between replay frames")
+	asciicast   = flag.String("asciicast", "", "Write winning moves as an asciicast file; the part name is added before the extension")
+)
 
 func absInt(a int) int {
 	if a >= 0 {
@@ -35,32 +56,6 @@ func absInt(a int) int {
 
 type Position struct{ hall, room, slot int }
 
-func (p Position) X() int {
-	if p.hall > 0 {
-		return p.hall
-	}
-	return p.room
-}
-
-func (p Position) dist(o Position) int {
-	if p.hall > 0 && p.room > 0 {
-		log.Fatalf("Invalid position: %v", p)
-	}
-	if o.hall > 0 && o.room > 0 {
-		log.Fatalf("Invalid position: %v", o)
-	}
-	if p.hall > 0 && o.room > 0 {
-		return absInt(p.hall-o.room) + o.slot
-	}
-	if o.hall > 0 && p.room > 0 {
-		return absInt(o.hall-p.room) + p.slot
-	}
-	if p.hall > 0 && o.hall > 0 { // not actually a legal move
-		return absInt(p.hall - o.hall)
-	}
-	return absInt(p.room-o.room) + p.slot + o.slot
-}
-
 func (p Position) String() string {
 	if p.hall > 0 && p.room > 0 {
 		log.Fatalf("Invalid position hall %d room %d slot %d", p.hall, p.room, p.slot)
@@ -71,326 +66,672 @@ func (p Position) String() string {
 	return fmt.Sprintf("{room: %d,slot: %d}", p.room, p.slot)
 }
 
-var validHall = []Position{
-	Position{hall: 1}, Position{hall: 2}, Position{hall: 4}, Position{hall: 6},
-	Position{hall: 8}, Position{hall: 10}, Position{hall: 11},
+// Burrow is the fixed layout shared by all boards in a puzzle.  X positions
+// are diagram columns, so the hallway starts at 1 and rooms are below it.
+type Burrow struct {
+	// diagram is the input with amphipods replaced by '.', used by Board.String.
+	diagram [][]byte
+	// rooms are the X positions of each room, in order; kind 'A'+i belongs in
+	// rooms[i].
+	rooms []int
+	// depth is the number of slots in the room at each X position, 0 for
+	// positions without a room.
+	depth []int
+	// validHall are hallway positions where an amphipod can stop, i.e. those
+	// not immediately outside a room, in X order.
+	validHall []Position
+	// costs are the energy per step for each kind, indexed by kind-'A'.
+	costs []int
+	// cells are the positions an amphipod can occupy: validHall followed by
+	// the slots of each room, top to bottom.  State stores one value per cell.
+	cells []Position
+	// roomCell[i][slot] is the cells index of a slot in rooms[i]; slot 0 is
+	// unused so indexes match Position.slot.
+	roomCell [][]int
+	// cellBits is the number of bits per cell in a State and perWord is the
+	// number of cells in each uint64.
+	cellBits, perWord int
+	// goal is the state with every room full of its own kind.
+	goal State
 }
 
-type Amphipod struct {
-	kind         byte
-	pos          Position
-	target, cost int
+func (w *Burrow) isRoom(x int) bool { return x < len(w.depth) && w.depth[x] > 0 }
+
+// State is a compact encoding of amphipod positions, used as the key in
+// solve's maps.  Each of Burrow.cells is cellBits bits holding 0 for an empty
+// cell or kind-'A'+1 for an amphipod.  Cells don't straddle words.
+type State [4]uint64
+
+// stateWords is the number of uint64s in a State.
+const stateWords = len(State{})
+
+func (w *Burrow) get(s State, i int) int {
+	return int(s[i/w.perWord]>>((i%w.perWord)*w.cellBits)) & (1<<w.cellBits - 1)
 }
 
-func newAmphipod(kind byte, pos Position) Amphipod {
-	a := Amphipod{kind: kind, pos: pos}
-	switch kind {
-	case 'A':
-		a.target = 3
-		a.cost = 1
-	case 'B':
-		a.target = 5
-		a.cost = 10
-	case 'C':
-		a.target = 7
-		a.cost = 100
-	case 'D':
-		a.target = 9
-		a.cost = 1000
-	}
-	return a
+func (w *Burrow) set(s *State, i, v int) {
+	shift := (i % w.perWord) * w.cellBits
+	s[i/w.perWord] = s[i/w.perWord]&^((1<<w.cellBits-1)<<shift) | uint64(v)<<shift
 }
 
-func (a Amphipod) String() string {
-	return fmt.Sprintf("Amphipod{kind: %q, pos: %s, target: %d, cost: %d}", a.kind, a.pos, a.target, a.cost)
+// layoutCells fills in cells, roomCell, the State encoding sizes, and goal.
+func (w *Burrow) layoutCells() {
+	w.cells = append(w.cells, w.validHall...)
+	w.roomCell = make([][]int, len(w.rooms))
+	for i, x := range w.rooms {
+		w.roomCell[i] = make([]int, w.depth[x]+1)
+		w.roomCell[i][0] = -1
+		for slot := 1; slot <= w.depth[x]; slot++ {
+			w.roomCell[i][slot] = len(w.cells)
+			w.cells = append(w.cells, Position{room: x, slot: slot})
+		}
+	}
+	w.cellBits = bits.Len(uint(len(w.rooms)))
+	w.perWord = 64 / w.cellBits
+	if len(w.cells) > stateWords*w.perWord {
+		log.Fatalf("Burrow has %d cells at %d bits each, too big for %d-word State", len(w.cells), w.cellBits, stateWords)
+	}
+	for i := range w.rooms {
+		for _, c := range w.roomCell[i][1:] {
+			w.set(&w.goal, c, i+1)
+		}
+	}
 }
 
-type BoardKey string
-type Board struct {
-	pods        []Amphipod
-	depth, cost int
-	k           BoardKey
+// cellIndex returns the cells index of p, or -1 if amphipods can't stop at p.
+func (w *Burrow) cellIndex(p Position) int {
+	for i, c := range w.cells {
+		if c == p {
+			return i
+		}
+	}
+	return -1
 }
 
-func newBoard(cost int, depth int, pods ...Amphipod) *Board {
-	aps := make([]Amphipod, len(pods))
-	copy(aps, pods)
-	return &Board{pods: aps, depth: depth, cost: cost}
+type Board struct {
+	burrow *Burrow
+	state  State
+	cost   int
 }
 
-func (b *Board) key() BoardKey {
-	if b.k == BoardKey("") {
-		b.k = BoardKey(b.String())
+// top returns the highest occupied slot in rooms[r] and the kind in it (1
+// for A), or 0, 0 if the room is empty.
+func (b Board) top(r int) (slot, kind int) {
+	for slot, c := range b.burrow.roomCell[r][1:] {
+		if k := b.burrow.get(b.state, c); k != 0 {
+			return slot + 1, k
+		}
 	}
-	return b.k
+	return 0, 0
 }
 
-func (b *Board) validMoves() []*Board {
-	res := make([]*Board, 0, 8)
-	for i, a := range b.pods {
-		if a.pos.room != a.target {
-			for j := b.depth; j > 0; j-- {
-				p := Position{room: a.target, slot: j}
-				if b.validMove(a, p) {
-					res = append(res, b.move(i, p))
-					break
-				}
-			}
-		}
-		if a.pos.room > 0 && (a.pos.room != a.target || !b.roomSatisfied(a.target)) {
-			for _, p := range validHall {
-				if b.validMove(a, p) {
-					res = append(res, b.move(i, p))
-				}
-			}
+// settledFrom reports whether every slot in rooms[r] from slot to the bottom
+// holds an amphipod that belongs there.
+func (b Board) settledFrom(r, slot int) bool {
+	for _, c := range b.burrow.roomCell[r][slot:] {
+		if b.burrow.get(b.state, c) != r+1 {
+			return false
 		}
 	}
-	return res
+	return true
 }
 
-func (b *Board) validMove(a Amphipod, p Position) bool {
-	ap := a.pos
-	if p == ap {
-		return false
-	}
-	if p.hall > 0 && ap.hall > 0 {
-		return false
+// openSlot returns the slot an amphipod of kind r+1 would move to in rooms[r],
+// or 0 if the room is full or holds a different kind.
+func (b Board) openSlot(r int) int {
+	slot, _ := b.top(r)
+	if slot == 0 {
+		return b.burrow.depth[b.burrow.rooms[r]]
 	}
-	if p.room > 0 && a.target != p.room {
-		return false
+	if slot == 1 || !b.settledFrom(r, slot) {
+		return 0
 	}
-	if p.hall == 3 || p.hall == 5 || p.hall == 7 || p.hall == 9 {
-		return false // can't stop outside a room
+	return slot - 1
+}
+
+// hallClear reports whether an amphipod at X position from can get to X
+// position to without passing through another amphipod.  from itself is not
+// checked.
+func (b Board) hallClear(from, to int) bool {
+	lo, hi := min(from, to), max(from, to)
+	for i, p := range b.burrow.validHall {
+		if p.hall >= lo && p.hall <= hi && p.hall != from && b.burrow.get(b.state, i) != 0 {
+			return false
+		}
 	}
-	sawSlot := make([]bool, b.depth+1)
-	for _, o := range b.pods {
-		if o == a {
+	return true
+}
+
+// move returns a board with the amphipod of the given kind moved from cell
+// src to cell dst, taking steps steps.
+func (b Board) move(src, dst, kind, steps int) Board {
+	m := Board{burrow: b.burrow, state: b.state, cost: b.cost + steps*b.burrow.costs[kind-1]}
+	b.burrow.set(&m.state, src, 0)
+	b.burrow.set(&m.state, dst, kind)
+	return m
+}
+
+func (b Board) validMoves() []Board {
+	w := b.burrow
+	res := make([]Board, 0, 8)
+	for h, p := range w.validHall {
+		kind := w.get(b.state, h)
+		if kind == 0 {
 			continue
 		}
-		op := o.pos
-		if op == p {
-			return false
+		t := kind - 1
+		if slot := b.openSlot(t); slot > 0 && b.hallClear(p.hall, w.rooms[t]) {
+			res = append(res, b.move(h, w.roomCell[t][slot], kind, absInt(p.hall-w.rooms[t])+slot))
 		}
-		if op.hall > 0 {
-			if ap.X() < p.X() && ap.X() <= op.X() && op.X() <= p.X() {
-				return false
-			}
-			if ap.X() > p.X() && ap.X() >= op.X() && op.X() >= p.X() {
-				return false
-			}
+	}
+	for r, x := range w.rooms {
+		slot, kind := b.top(r)
+		if kind == 0 || b.settledFrom(r, slot) {
+			continue
 		}
-		if op.room > 0 && op.room == p.room {
-			if o.kind != a.kind {
-				return false
+		src := w.roomCell[r][slot]
+		if t := kind - 1; t != r {
+			if ts := b.openSlot(t); ts > 0 && b.hallClear(x, w.rooms[t]) {
+				res = append(res, b.move(src, w.roomCell[t][ts], kind, slot+absInt(x-w.rooms[t])+ts))
 			}
-			if op.slot < p.slot {
-				return false
+		}
+		for h, p := range w.validHall {
+			if w.get(b.state, h) == 0 && b.hallClear(x, p.hall) {
+				res = append(res, b.move(src, h, kind, slot+absInt(x-p.hall)))
 			}
-			sawSlot[op.slot] = true
 		}
-		if op.room > 0 && op.room == ap.room && op.slot < ap.slot {
-			return false
+	}
+	return res
+}
+
+func (b Board) satisfied() bool {
+	return b.state == b.burrow.goal
+}
+
+// minRemainingCost is a lower bound on the cost to finish from this board:
+// each amphipod not yet settled has to walk to the hallway above its room (an
+// amphipod in its own room but blocking another kind needs to step aside and
+// back) and then down into the room's unsettled slots.
+func (b Board) minRemainingCost() int {
+	w := b.burrow
+	res := 0
+	settled := make([]int, len(w.rooms))
+	for r, x := range w.rooms {
+		d := w.depth[x]
+		for settled[r] < d && w.get(b.state, w.roomCell[r][d-settled[r]]) == r+1 {
+			settled[r]++
 		}
+		need := d - settled[r]
+		res += w.costs[r] * need * (need + 1) / 2
 	}
-	if p.slot > 0 {
-		for i := p.slot + 1; i <= b.depth; i++ {
-			if !sawSlot[i] {
-				return false
-			}
+	for i, p := range w.cells {
+		kind := w.get(b.state, i)
+		if kind == 0 {
+			continue
+		}
+		t := kind - 1
+		tx := w.rooms[t]
+		switch {
+		case p.hall > 0:
+			res += w.costs[t] * absInt(p.hall-tx)
+		case p.room != tx:
+			res += w.costs[t] * (p.slot + absInt(p.room-tx))
+		case p.slot <= w.depth[tx]-settled[t]:
+			res += w.costs[t] * (p.slot + 2)
 		}
 	}
-	return true
+	return res
 }
 
-func (b *Board) move(i int, p Position) *Board {
-	a := b.pods[i]
-	anew := a
-	anew.pos = p
-	bnew := newBoard(a.cost*a.pos.dist(p)+b.cost, b.depth, b.pods...)
-	bnew.pods[i] = anew
-	return bnew
+func (b Board) String() string {
+	return b.render(-1)
 }
 
-func (b *Board) satisfied() bool {
-	for _, a := range b.pods {
-		if a.pos.room != a.target {
-			return false
+// highlightColor is the ANSI color for the amphipod which just moved.
+const highlightColor = "1;30;103" // bold black on bright yellow
+
+// render draws the board as a diagram.  If highlight is a cells index, the
+// amphipod in that cell is colored with ANSI escapes.
+func (b Board) render(highlight int) string {
+	grid := make([][]string, len(b.burrow.diagram))
+	for i, row := range b.burrow.diagram {
+		grid[i] = make([]string, len(row))
+		for j, c := range row {
+			grid[i][j] = string(c)
 		}
 	}
-	return true
+	for i, p := range b.burrow.cells {
+		kind := b.burrow.get(b.state, i)
+		if kind == 0 {
+			continue
+		}
+		c := string(rune('A' + kind - 1))
+		if i == highlight {
+			c = fmt.Sprintf("\x1B[%sm%s\x1B[0m", highlightColor, c)
+		}
+		if p.hall > 0 {
+			grid[1][p.hall] = c
+		} else {
+			grid[1+p.slot][p.room] = c
+		}
+	}
+	lines := make([]string, len(grid))
+	for i, row := range grid {
+		lines[i] = strings.Join(row, "")
+	}
+	return strings.Join(lines, "\n")
 }
 
-func (b *Board) roomSatisfied(room int) bool {
-	for _, a := range b.pods {
-		if a.target == room && a.pos.room != a.target {
-			return false
+// movedTo returns the cells index which is occupied in b but empty in prev.
+func (b Board) movedTo(prev Board) int {
+	for i := range b.burrow.cells {
+		if b.burrow.get(b.state, i) != 0 && b.burrow.get(prev.state, i) == 0 {
+			return i
 		}
 	}
-	return true
+	return -1
 }
 
-func (b *Board) minRemainingCost() int {
-	res := 0
-	targets := make(map[int][]Amphipod)
-	for _, a := range b.pods {
-		if _, ok := targets[a.target]; !ok {
-			targets[a.target] = make([]Amphipod, 0, 4)
-		}
-		targets[a.target] = append(targets[a.target], a)
-	}
-	for t, pods := range targets {
-		d := b.depth
-		for i := b.depth; i > 0; i-- {
-			for _, a := range pods {
-				if a.pos.room == t && a.pos.slot == i {
-					d--
-					break
-				}
-			}
-		}
-		if d == 0 {
-			continue // room fully satisfied
+// replayFrames renders each board in a winning path, highlighting the
+// amphipod which moved and showing the energy of that move and the total.
+func replayFrames(path []Board) []string {
+	frames := make([]string, len(path))
+	for i, b := range path {
+		if i == 0 {
+			frames[i] = fmt.Sprintf("Start\n%s\n", b)
+			continue
 		}
-		dc := d
-		for _, a := range pods {
-			if a.pos.room != t || a.pos.slot < d {
-				res += a.cost * (absInt(a.pos.X()-t) + dc)
-				dc--
-			}
+		prev := path[i-1]
+		to := b.movedTo(prev)
+		kind := byte('A' + b.burrow.get(b.state, to) - 1)
+		frames[i] = fmt.Sprintf("Move %d/%d: %c to %s costs %d, total %d\n%s\n",
+			i, len(path)-1, kind, b.burrow.cells[to], b.cost-prev.cost, b.cost, b.render(to))
+	}
+	return frames
+}
+
+// replay animates frames on a terminal, redrawing the screen for each one.
+func replay(w io.Writer, frames []string, delay time.Duration) {
+	for i, f := range frames {
+		if i > 0 {
+			time.Sleep(delay)
 		}
+		fmt.Fprintf(w, "\x1B[H\x1B[2J%s", f)
 	}
-	return res
 }
 
-func (b *Board) String() string {
-	lines := make([]string, b.depth+3)
-	lines[0] = "#############"
-	lines[b.depth+2] = "  #########  "
-	hall := []byte{'#', '.', '.', '.', '.', '.', '.', '.', '.', '.', '.', '.', '#'}
-	rooms := make([][]byte, b.depth)
-	for i := range rooms {
-		rooms[i] = []byte{' ', ' ', '#', '.', '#', '.', '#', '.', '#', '.', '#', ' ', ' '}
+// ansiEscape matches the color escapes added by render, which take up no
+// columns on the terminal.
+var ansiEscape = regexp.MustCompile("\x1B\\[[0-9;]*[A-Za-z]")
+
+// writeAsciicast writes frames as an asciicast v2 recording, which can be
+// played with asciinema or shared on asciinema.org.
+func writeAsciicast(w io.Writer, frames []string, delay time.Duration) error {
+	width, height := 0, 0
+	for _, f := range frames {
+		lines := strings.Split(ansiEscape.ReplaceAllString(f, ""), "\n")
+		height = max(height, len(lines))
+		for _, l := range lines {
+			width = max(width, utf8.RuneCountInString(l))
+		}
 	}
-	rooms[0] = []byte{'#', '#', '#', '.', '#', '.', '#', '.', '#', '.', '#', '#', '#'}
-	for _, p := range b.pods {
-		if p.pos.hall > 0 {
-			hall[p.pos.hall] = p.kind
+	header := map[string]any{
+		"version": 2, "width": width, "height": height,
+		"timestamp": time.Now().Unix(), "title": "Advent of Code 2021 day 23",
+	}
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for i, f := range frames {
+		// asciicast expects terminal output, which needs explicit carriage returns
+		out := "\x1B[H\x1B[2J" + strings.ReplaceAll(f, "\n", "\r\n")
+		if err := enc.Encode([]any{(time.Duration(i) * delay).Seconds(), "o", out}); err != nil {
+			return err
 		}
-		if p.pos.room > 0 {
-			rooms[p.pos.slot-1][p.pos.room] = p.kind
+	}
+	return nil
+}
+
+// castCount tracks asciicast file names so multiple input files don't
+// overwrite each other.
+var castCount = make(map[string]int)
+
+// showWinner logs, animates, or records a winning path based on flags.
+func showWinner(path []Board, partName string) {
+	if *printWinner {
+		for _, b := range path {
+			log.Printf("Cost %d\n%s\n", b.cost, b)
 		}
 	}
-	lines[1] = string(hall)
-	for i, r := range rooms {
-		lines[2+i] = string(r)
+	if !*replayMoves && *asciicast == "" {
+		return
 	}
-	return strings.Join(lines, "\n")
+	frames := replayFrames(path)
+	if *replayMoves {
+		replay(os.Stderr, frames, *replayDelay)
+	}
+	if *asciicast != "" {
+		ext := filepath.Ext(*asciicast)
+		base := strings.TrimSuffix(*asciicast, ext) + "." + partName
+		fname := base
+		if n := castCount[base]; n > 0 {
+			fname += fmt.Sprintf(".%d", n)
+		}
+		castCount[base]++
+		fname += ext
+		f, err := os.Create(fname)
+		if err != nil {
+			log.Fatalf("Could not create %s: %v", fname, err)
+		}
+		defer f.Close()
+		if err := writeAsciicast(f, frames, *replayDelay); err != nil {
+			log.Fatalf("Error writing %s: %v", fname, err)
+		}
+		log.Printf("Wrote %d moves to %s", len(path)-1, fname)
+	}
+}
+
+// heuristic estimates the remaining cost to organize a board.
+type heuristic func(Board) int
+
+func zeroHeuristic(Board) int { return 0 }
+
+// searchStats counts work done by search.  inconsistent counts moves where the
+// heuristic dropped by more than the move cost, which would make A* unsound.
+type searchStats struct {
+	expanded, seenSkipped, inconsistent int
 }
 
-func solve(initial *Board) int {
-	seen := make(map[BoardKey]int)
-	seen[initial.key()] = 0
-	parent := make(map[*Board]*Board)
-	q := make(map[int][]*Board)
-	q[0] = []*Board{initial}
-	var pri, seenSkipped int
-	for {
+// search finds the minimum cost to organize the amphipods and the sequence of
+// boards from initial to the organized one using A* with heuristic h.  With
+// zeroHeuristic this is Dijkstra's algorithm.  Cost is -1 if the board can't
+// be solved.
+func search(initial Board, h heuristic) (int, []Board, searchStats) {
+	var stats searchStats
+	seen := make(map[State]int)
+	seen[initial.state] = 0
+	parent := make(map[State]Board)
+	q := make(map[int][]Board)
+	q[0] = []Board{initial}
+	pri := 0
+	for len(q) > 0 {
 		for q[pri] == nil || len(q[pri]) == 0 {
 			pri++
-			if pri > 1000000 {
-				log.Fatalf("Somehow got to cost %d, seen %d boards", pri, len(seen))
-			}
 		}
 		for i := 0; i < len(q[pri]); i++ {
 			b := q[pri][i]
 			if b.satisfied() {
-				log.Printf("Found winner at cost %d with %d seen skipped:\n", pri, seenSkipped)
-				if *printWinner {
-					for x := b; x != nil; x = parent[x] {
-						log.Printf("Cost %d\n%s\n", x.cost, x)
-					}
+				path := make([]Board, 0)
+				for x, ok := b, true; ok; x, ok = parent[x.state] {
+					path = append(path, x)
 				}
-				return b.cost
+				slices.Reverse(path)
+				return b.cost, path, stats
 			}
+			stats.expanded++
+			hb := h(b)
 			for _, m := range b.validMoves() {
-				key := m.key()
-				rem := m.minRemainingCost()
-				if prev, ok := seen[key]; ok && prev <= m.cost+rem {
-					seenSkipped++
-					continue
+				rem := h(m)
+				if hb > m.cost-b.cost+rem {
+					stats.inconsistent++
 				}
-				seen[key] = m.cost + rem
-				parent[m] = b
-				c := m.cost + rem
-				if q[c] == nil {
-					q[c] = make([]*Board, 0)
+				if prev, ok := seen[m.state]; ok && prev <= m.cost+rem {
+					stats.seenSkipped++
+					continue
 				}
+				seen[m.state] = m.cost + rem
+				parent[m.state] = b
+				// an inconsistent heuristic could produce a lower priority
+				c := max(m.cost+rem, pri)
 				q[c] = append(q[c], m)
 			}
 		}
 		delete(q, pri)
 	}
-	log.Fatalf("Somehow ran out of boards at cost %d, seen %d boards", pri, len(seen))
-	return 0
+	return -1, nil, stats
 }
 
-func runPart(part int, initial *Board, expected int, name string) bool {
-	fmt.Printf("Running part %d on %s expecting %d\n", part, name, expected)
-	start := time.Now()
-	res := solve(initial)
-	dur := time.Since(start)
-	m := "❌"
-	if res == expected {
-		m = "✓"
-	}
-	fmt.Printf("%s Part %d on %s got %d in %s\n", m, part, name, res, dur)
-	if expected != 0 && res != expected {
-		fmt.Printf("❌ got %d but want %d\n\n", res, expected)
-		return false
+// solve uses A* with minRemainingCost to find the minimum cost to organize
+// the amphipods and the sequence of boards from initial to the organized one.
+func solve(initial Board) (int, []Board) {
+	cost, path, stats := search(initial, Board.minRemainingCost)
+	if cost < 0 {
+		log.Printf("Ran out of boards after expanding %d", stats.expanded)
+	} else {
+		log.Printf("Found winner at cost %d with %d seen skipped:\n", cost, stats.seenSkipped)
 	}
-	fmt.Println()
-	return true
+	return cost, path
 }
 
-var part1Example = newBoard(0, 2,
-	newAmphipod('B', Position{room: 3, slot: 1}), newAmphipod('A', Position{room: 3, slot: 2}),
-	newAmphipod('C', Position{room: 5, slot: 1}), newAmphipod('D', Position{room: 5, slot: 2}),
-	newAmphipod('B', Position{room: 7, slot: 1}), newAmphipod('C', Position{room: 7, slot: 2}),
-	newAmphipod('D', Position{room: 9, slot: 1}), newAmphipod('A', Position{room: 9, slot: 2}),
+var (
+	verify   = flag.Bool("verify", false, "Check A* against Dijkstra's algorithm and check the heuristic")
+	fuzz     = flag.Int("fuzz", 0, "Compare A* and Dijkstra's algorithm on this many random boards")
+	fuzzSeed = flag.Int64("fuzz-seed", 1, "Random seed for -fuzz")
 )
 
-var part1Actual = newBoard(0, 2,
-	newAmphipod('C', Position{room: 3, slot: 1}), newAmphipod('B', Position{room: 3, slot: 2}),
-	newAmphipod('B', Position{room: 5, slot: 1}), newAmphipod('D', Position{room: 5, slot: 2}),
-	newAmphipod('D', Position{room: 7, slot: 1}), newAmphipod('A', Position{room: 7, slot: 2}),
-	newAmphipod('A', Position{room: 9, slot: 1}), newAmphipod('C', Position{room: 9, slot: 2}),
-)
+// compareSolvers runs search with minRemainingCost and with no heuristic,
+// exiting if they disagree or if minRemainingCost overestimates the remaining
+// cost of any board on the optimal path.  Returns the optimal cost.
+func compareSolvers(b Board, name string) int {
+	astar, _, astats := search(b, Board.minRemainingCost)
+	dijkstra, path, dstats := search(b, zeroHeuristic)
+	log.Printf("%s: A* cost %d expanded %d seen skipped %d inconsistent %d; Dijkstra cost %d expanded %d seen skipped %d",
+		name, astar, astats.expanded, astats.seenSkipped, astats.inconsistent, dijkstra, dstats.expanded, dstats.seenSkipped)
+	if astar != dijkstra {
+		log.Fatalf("%s: A* got %d but Dijkstra got %d\n%s", name, astar, dijkstra, b)
+	}
+	for _, p := range path {
+		if h, actual := p.minRemainingCost(), dijkstra-p.cost; h > actual {
+			log.Fatalf("%s: minRemainingCost %d is more than actual %d, not admissible\n%s", name, h, actual, p)
+		}
+	}
+	if astats.inconsistent > 0 {
+		log.Printf("%s: minRemainingCost is admissible on the optimal path but not consistent", name)
+	}
+	return dijkstra
+}
 
-var part2Example = newBoard(0, 4,
-	newAmphipod('B', Position{room: 3, slot: 1}), newAmphipod('D', Position{room: 3, slot: 2}), newAmphipod('D', Position{room: 3, slot: 3}), newAmphipod('A', Position{room: 3, slot: 4}),
-	newAmphipod('C', Position{room: 5, slot: 1}), newAmphipod('C', Position{room: 5, slot: 2}), newAmphipod('B', Position{room: 5, slot: 3}), newAmphipod('D', Position{room: 5, slot: 4}),
-	newAmphipod('B', Position{room: 7, slot: 1}), newAmphipod('B', Position{room: 7, slot: 2}), newAmphipod('A', Position{room: 7, slot: 3}), newAmphipod('C', Position{room: 7, slot: 4}),
-	newAmphipod('D', Position{room: 9, slot: 1}), newAmphipod('A', Position{room: 9, slot: 2}), newAmphipod('C', Position{room: 9, slot: 3}), newAmphipod('A', Position{room: 9, slot: 4}),
-)
+// randomBoard shuffles the amphipods in like's burrow into its rooms, then
+// makes up to maxMoves random valid moves.  The result may not be solvable.
+func randomBoard(like Board, rnd *rand.Rand, maxMoves int) Board {
+	w := like.burrow
+	kinds := make([]int, 0, len(w.cells))
+	for r, x := range w.rooms {
+		for i := 0; i < w.depth[x]; i++ {
+			kinds = append(kinds, r+1)
+		}
+	}
+	rnd.Shuffle(len(kinds), func(i, j int) { kinds[i], kinds[j] = kinds[j], kinds[i] })
+	b := Board{burrow: w}
+	for r := range w.rooms {
+		for _, c := range w.roomCell[r][1:] {
+			w.set(&b.state, c, kinds[0])
+			kinds = kinds[1:]
+		}
+	}
+	for n := rnd.Intn(maxMoves + 1); n > 0; n-- {
+		moves := b.validMoves()
+		if len(moves) == 0 {
+			break
+		}
+		b = moves[rnd.Intn(len(moves))]
+	}
+	b.cost = 0
+	return b
+}
 
-var part2Actual = newBoard(0, 4,
-	newAmphipod('C', Position{room: 3, slot: 1}), newAmphipod('D', Position{room: 3, slot: 2}), newAmphipod('D', Position{room: 3, slot: 3}), newAmphipod('B', Position{room: 3, slot: 4}),
-	newAmphipod('B', Position{room: 5, slot: 1}), newAmphipod('C', Position{room: 5, slot: 2}), newAmphipod('B', Position{room: 5, slot: 3}), newAmphipod('D', Position{room: 5, slot: 4}),
-	newAmphipod('D', Position{room: 7, slot: 1}), newAmphipod('B', Position{room: 7, slot: 2}), newAmphipod('A', Position{room: 7, slot: 3}), newAmphipod('A', Position{room: 7, slot: 4}),
-	newAmphipod('A', Position{room: 9, slot: 1}), newAmphipod('A', Position{room: 9, slot: 2}), newAmphipod('C', Position{room: 9, slot: 3}), newAmphipod('C', Position{room: 9, slot: 4}),
-)
+// fuzzSolvers runs compareSolvers on n random solvable boards in like's
+// burrow.
+func fuzzSolvers(like Board, n int) {
+	rnd := rand.New(rand.NewSource(*fuzzSeed))
+	solved, unsolvable := 0, 0
+	for solved < n {
+		b := randomBoard(like, rnd, 4)
+		name := fmt.Sprintf("fuzz attempt %d", solved+unsolvable+1)
+		if compareSolvers(b, name) < 0 {
+			log.Printf("%s: unsolvable, skipping", name)
+			unsolvable++
+			continue
+		}
+		solved++
+	}
+	log.Printf("Fuzzed %d boards, skipped %d unsolvable", solved, unsolvable)
+}
 
-func main() {
-	var success [4]bool
-	success[0] = runPart(1, part1Example, 12521, "input.example.txt")
-	success[1] = runPart(1, part1Actual, 13520, "input.actual.txt")
-	success[2] = runPart(2, part2Example, 44169, "input.example.txt")
-	success[3] = runPart(2, part2Actual, 48708, "input.actual.txt")
-	fmt.Printf("Success? %v\n", success)
-	for _, s := range success {
-		if !s {
-			os.Exit(1)
+// runPart solves a board, optionally verifying the solver and showing the
+// winning moves.
+func runPart(b Board, partName string) string {
+	if *verify {
+		compareSolvers(b, partName)
+	}
+	if *fuzz > 0 {
+		fuzzSolvers(b, *fuzz)
+	}
+	cost, path := solve(b)
+	showWinner(path, partName)
+	return fmt.Sprint(cost)
+}
+
+// unfoldRows are inserted after the first room row in part 2.
+var unfoldRows = []string{"  #D#C#B#A#", "  #D#B#A#C#"}
+
+func isPodOrSpace(c byte) bool { return c == '.' || (c >= 'A' && c <= 'Z') }
+
+// parseBoard reads a burrow diagram.  Line 0 is the wall above the hallway,
+// line 1 is the hallway, and each subsequent line is one row of room slots.  A
+// character's column is its hallway or room X position.  Rooms are columns
+// with open space below the hallway, and a room's depth is the number of open
+// spaces in its column.  An optional line after the diagram sets move costs.
+func parseBoard(lines []string) Board {
+	if len(lines) < 3 {
+		log.Fatalf("Expected at least 3 lines in burrow diagram, got %d", len(lines))
+	}
+	if !strings.HasPrefix(lines[0], "#") || !strings.HasPrefix(lines[1], "#") {
+		log.Fatalf("Expected burrow diagram to start with walls and hallway, got %q %q", lines[0], lines[1])
+	}
+	w := &Burrow{diagram: make([][]byte, 0, len(lines))}
+	var costLine string
+	for i, line := range lines {
+		if i > 1 && strings.Contains(line, "=") {
+			costLine = line
+			break
+		}
+		if line == "" {
+			continue
+		}
+		w.diagram = append(w.diagram, []byte(line))
+	}
+	width := 0
+	for _, row := range w.diagram {
+		width = max(width, len(row))
+	}
+	w.depth = make([]int, width)
+	hall := w.diagram[1]
+	for x := range hall {
+		if !isPodOrSpace(hall[x]) {
+			continue
+		}
+		for y := 2; y < len(w.diagram) && x < len(w.diagram[y]) && isPodOrSpace(w.diagram[y][x]); y++ {
+			w.depth[x]++
+		}
+		if w.depth[x] > 0 {
+			w.rooms = append(w.rooms, x)
+		}
+	}
+	for x := range hall {
+		if isPodOrSpace(hall[x]) && w.depth[x] == 0 {
+			w.validHall = append(w.validHall, Position{hall: x})
+		}
+	}
+	if len(w.rooms) == 0 || len(w.rooms) > 26 {
+		log.Fatalf("Expected 1 to 26 rooms, got %d", len(w.rooms))
+	}
+	w.costs = make([]int, len(w.rooms))
+	for i := range w.costs {
+		w.costs[i] = 1
+		if i > 0 {
+			w.costs[i] = w.costs[i-1] * 10
+		}
+	}
+	if costLine != "" {
+		for _, f := range strings.Fields(costLine) {
+			k, v, ok := strings.Cut(f, "=")
+			c, err := strconv.Atoi(v)
+			if !ok || len(k) != 1 || k[0] < 'A' || int(k[0]-'A') >= len(w.costs) || err != nil {
+				log.Fatalf("Invalid cost %q in %q", f, costLine)
+			}
+			w.costs[k[0]-'A'] = c
+		}
+	}
+	w.layoutCells()
+	b := Board{burrow: w}
+	counts := make([]int, len(w.rooms))
+	for y, row := range w.diagram[1:] {
+		for x, c := range row {
+			switch {
+			case c >= 'A' && c <= 'Z':
+				pos := Position{hall: x}
+				if y > 0 {
+					pos = Position{room: x, slot: y}
+				}
+				i := w.cellIndex(pos)
+				if int(c-'A') >= len(w.rooms) {
+					log.Fatalf("Amphipod kind %q has no room, only %d rooms", c, len(w.rooms))
+				}
+				if i < 0 {
+					log.Fatalf("Amphipod %c at line %d column %d can't stop there", c, y+2, x+1)
+				}
+				w.set(&b.state, i, int(c-'A')+1)
+				counts[c-'A']++
+				row[x] = '.'
+			case c != '#' && c != '.' && c != ' ':
+				log.Fatalf("Unexpected character %q at line %d column %d: %q", c, y+2, x+1, row)
+			}
+			if isPodOrSpace(c) && y > 0 && y > w.depth[x] {
+				log.Fatalf("Open space at line %d column %d isn't in a room: %q", y+2, x+1, row)
+			}
+		}
+	}
+	for i, r := range w.rooms {
+		if counts[i] != w.depth[r] {
+			log.Fatalf("Room %c at column %d holds %d but there are %d %c amphipods", 'A'+i, r+1, w.depth[r], counts[i], 'A'+i)
+		}
+	}
+	return b
+}
+
+// unfold returns the diagram with unfoldRows inserted after the first row of
+// rooms.  Only the standard four-room layout can be unfolded.
+func unfold(lines []string) ([]string, bool) {
+	if len(lines) < 3 || strings.Trim(lines[2], "# ") == "" || len(lines[2]) < 11 {
+		return nil, false
+	}
+	for x, c := range lines[2] {
+		if isRoom := x == 3 || x == 5 || x == 7 || x == 9; isRoom != isPodOrSpace(byte(c)) {
+			return nil, false
 		}
 	}
-	os.Exit(0)
+	res := make([]string, 0, len(lines)+len(unfoldRows))
+	res = append(res, lines[:3]...)
+	res = append(res, unfoldRows...)
+	return append(res, lines[3:]...), true
+}
+
+func part1(lines []string) string {
+	return runPart(parseBoard(lines), "part1")
 }
+
+func part2(lines []string) string {
+	unfolded, ok := unfold(lines)
+	if !ok {
+		return "part 2 needs the standard four-room burrow"
+	}
+	return runPart(parseBoard(unfolded), "part2")
+}
+
+func main() {
+	runMain(part1, part2)
+}
+
+const dayName = "day23"
@@ -7,21 +7,92 @@
 // https://adventofcode.com/2021/day/24
 // Finds the maximum/minimum 14-digit numbers with no 0s which result in a
 // progam with 4 registers and a limited set of opcodes to produce a 0 value in
-// the z register at the end of the program.  Depends on a generated function
-// named Compute_inputactual (because I name my AoC input file
-// input.actual.txt) which is produced by genday24.go.
+// the z register at the end of the program.  Depends on generated functions
+// produced by genday24.go, e.g. Compute_inputactual (because I name my AoC
+// input file input.actual.txt), which register themselves in programs.  Run
+// go run genday24.go path/to/input.txt
+// go run day24.go runner.go input*.go -v path/to/input.txt
+// The runner passes the file's lines, so programs are looked up by a hash of
+// their source text rather than the file name.
+//
+// The search can take hours, so exhausted ranges and the progress of in-flight
+// ranges are periodically written to a JSON checkpoint file.  Run with -resume
+// to skip over everything a previous run already ruled out.
 package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"math"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Program is an ALU program compiled to Go by genday24.go.
+type Program struct {
+	// Name is the input file the code was generated from.
+	Name string
+	// Suffix is the identifier suffix of the generated functions.
+	Suffix string
+	// Compute runs the full program, returning the final z and the value of z
+	// before each inp instruction.
+	Compute func(input [14]int) (int, [15]int)
+	// Stage runs the instructions for one digit given z from the prior stage.
+	Stage func(digit, z, in int) int
+	// DivZ is the amount z is divided by in each stage, or 0 if unknown.
+	DivZ [14]int
+	// StagesIndependent is true if each stage writes w, x, and y before
+	// reading them, so Stage matches Compute and stages only share z.
+	StagesIndependent bool
+
+	checkpoint *searchState
+	loadOnce   sync.Once
+	dp         *dpResult
+}
+
+// programs maps the hash of an ALU program's source to its generated code.
+var programs = make(map[string]*Program)
+
+// registerProgram is called by generated code to add a program to programs.
+func registerProgram(hash string, p *Program) {
+	if prev, ok := programs[hash]; ok {
+		log.Printf("%s and %s have the same source, using %s", prev.Name, p.Name, p.Name)
+	}
+	p.checkpoint = &searchState{}
+	programs[hash] = p
+}
+
+// programHash returns the key for a program's source lines in programs.
+// genday24.go computes the same hash.
+func programHash(lines []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupProgram finds the generated code for an input file's lines.
+func lookupProgram(lines []string) (*Program, error) {
+	p, ok := programs[programHash(lines)]
+	if !ok {
+		names := make([]string, 0, len(programs))
+		for _, p := range programs {
+			names = append(names, p.Name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("no generated code for this input, run genday24.go on it (have %s)", strings.Join(names, ", "))
+	}
+	return p, nil
+}
+
 type Input [14]int
 
 func NewInput(val int) Input {
@@ -77,16 +148,35 @@ func MaybeNegativeRange(min, max int) Range {
 // accepted by the problem program it sends it to the out channel and returns.
 // If all inputs in the range are invalid it sends the range to the empty
 // channel.  It checks for context cancellation before checking each input.
-func searchRange(ctx context.Context, r Range, factor int, out chan<- Input, empty chan<- Range) {
+// Values already known to be invalid from the checkpoint are skipped, and
+// progress is recorded in the checkpoint every progressInterval values.
+func searchRange(ctx context.Context, p *Program, r Range, factor int, out chan<- Input, empty chan<- Range) {
+	skip := p.checkpoint.knownInvalid(r, factor)
+	checked := 0
 	for i := r.max; i >= r.min; i-- {
+		for len(skip) > 0 && skip[0].max >= i {
+			if skip[0].min <= i {
+				i = skip[0].min - 1
+			}
+			skip = skip[1:]
+		}
+		if i < r.min {
+			break
+		}
+		if checked%progressInterval == 0 {
+			p.checkpoint.progress(r, factor, i)
+		}
+		checked++
 		select {
 		case <-ctx.Done():
+			p.checkpoint.progress(r, factor, i)
 			return
 		default:
 			input := NewInput(factor * i)
-			z, _ := Compute_inputactual(input)
+			z, _ := p.Compute(input)
 			if z == 0 {
 				log.Printf("Found z=0 for %s in range %s", input, r)
+				p.checkpoint.progress(r, factor, i)
 				select {
 				case out <- input:
 					return
@@ -97,20 +187,25 @@ func searchRange(ctx context.Context, r Range, factor int, out chan<- Input, emp
 		}
 	}
 	log.Printf("Found no z=0 inputs in range %s", r)
+	p.checkpoint.complete(r, factor)
 	empty <- r
 }
 
-const numWorkers = 10 // I happen to have a 12-CPU workstation
+// progressInterval is the number of inputs searchRange checks between
+// checkpoint updates.
+const progressInterval = 1 << 22
+
+var numWorkers = runtime.NumCPU()
 
 // scanRange splits the range min..max into sub-ranges and dispatches each to a
 // goroutine which searches the whole range.  The first time a valid input is
 // found, all workers are cancelled and the range between the found input and
 // the max are scanned.  If the right workers have completed their range, the
 // new max will be reduced, but this rarely helps since workers generally all
-// finish at roughly the same time, assuming numWorkes < num CPUs.  The factor
+// finish at roughly the same time, assuming numWorkers <= num CPUs.  The factor
 // parameter can be 1 to find the maximum valid input or -1 to find the minimum
 // input.
-func scanRange(ctx context.Context, min, max, factor int) *Input {
+func scanRange(ctx context.Context, p *Program, min, max, factor int) *Input {
 	if factor != 1 && factor != -1 {
 		panic(fmt.Errorf("Expected factor to be 1 or -1, not %d", factor))
 	}
@@ -122,13 +217,13 @@ func scanRange(ctx context.Context, min, max, factor int) *Input {
 	var expected int
 	if (max - min) <= numWorkers {
 		expected = 1
-		go searchRange(ctxchild, MaybeNegativeRange(factor*min, factor*max), factor, valid, empty)
+		go searchRange(ctxchild, p, MaybeNegativeRange(factor*min, factor*max), factor, valid, empty)
 	} else {
 		expected = numWorkers
 		size := (max - min) / numWorkers
 		for i := 0; i < numWorkers; i++ {
 			r := MaybeNegativeRange(factor*(max-size*i), factor*(max-size*(i+1)+1))
-			go searchRange(ctxchild, r, factor, valid, empty)
+			go searchRange(ctxchild, p, r, factor, valid, empty)
 		}
 	}
 	var found *Input
@@ -175,7 +270,7 @@ func scanRange(ctx context.Context, min, max, factor int) *Input {
 			break outermin
 		}
 	}
-	better := scanRange(ctx, min, max, factor)
+	better := scanRange(ctx, p, min, max, factor)
 	if better == nil {
 		log.Printf("Found %s in %d..%d", *found, min, max)
 		return found
@@ -184,9 +279,9 @@ func scanRange(ctx context.Context, min, max, factor int) *Input {
 	return better
 }
 
-// part1 prints the maximum valid input for the program.
-func part1() {
-	start := time.Now()
+// maxByRange returns the maximum valid input for the program by scanning
+// ranges of inputs.
+func maxByRange(p *Program) *Input {
 	var winner *Input
 	for i := 13; i >= 0; i-- {
 		var high, low Input
@@ -199,22 +294,17 @@ func part1() {
 			low[j] = 1
 		}
 		high[i] = 8
-		winner = scanRange(context.Background(), low.Int(), high.Int(), 1)
+		winner = scanRange(context.Background(), p, low.Int(), high.Int(), 1)
 		if winner != nil {
 			break
 		}
 	}
-	dur := time.Since(start)
-	if winner == nil {
-		fmt.Println("❌ Part 1 has no winners!!! 😦")
-	} else {
-		fmt.Printf("✓ Part 1 found winner: %s in %s\n", winner, dur)
-	}
+	return winner
 }
 
-// part2 prints the minimum valid input for the program.
-func part2() {
-	start := time.Now()
+// minByRange returns the minimum valid input for the program by scanning
+// ranges of inputs.
+func minByRange(p *Program) *Input {
 	var winner *Input
 	for i := 13; i >= 0; i-- {
 		var high, low Input
@@ -228,42 +318,260 @@ func part2() {
 		}
 		high[i] = 9
 		low[i] = 2
-		winner = scanRange(context.Background(), low.Int(), high.Int(), -1)
+		winner = scanRange(context.Background(), p, low.Int(), high.Int(), -1)
 		if winner != nil {
 			break
 		}
 	}
-	dur := time.Since(start)
+	return winner
+}
+
+// searchByRange runs maxByRange or minByRange, saving checkpoints as it goes.
+// If running the program more than once was valuable, some state could
+// perhaps be preserved from part1, but since my part 1 answer started with 99,
+// not much work would be saved in part 2.  Ranges ruled out while searching for
+// the maximum are still skipped in the search for the minimum.
+func searchByRange(p *Program, search func(*Program) *Input) *Input {
+	fname := checkpointPath(p)
+	if *resume && fname != "" {
+		p.loadOnce.Do(func() {
+			if err := p.checkpoint.load(fname); err != nil {
+				log.Fatalf("Could not resume from %s: %v", fname, err)
+			}
+		})
+	}
+	stop := p.checkpoint.saveEvery(fname, *checkpointEvery)
+	defer stop()
+	return search(p)
+}
+
+// checkpointPath returns the checkpoint file name for p: the -checkpoint flag
+// with the program's suffix inserted before the extension.
+func checkpointPath(p *Program) string {
+	if *checkpointFile == "" {
+		return ""
+	}
+	ext := filepath.Ext(*checkpointFile)
+	return strings.TrimSuffix(*checkpointFile, ext) + "." + p.Suffix + ext
+}
+
+func solve(lines []string, part int) string {
+	p, err := lookupProgram(lines)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var winner *Input
+	switch *search {
+	case "range":
+		if part == 1 {
+			winner = searchByRange(p, maxByRange)
+		} else {
+			winner = searchByRange(p, minByRange)
+		}
+	case "dp":
+		if p.dp == nil {
+			res := searchDP(p)
+			p.dp = &res
+		}
+		if p.dp.ok && part == 1 {
+			w := digits(p.dp.max)
+			winner = &w
+		} else if p.dp.ok {
+			w := digits(p.dp.min)
+			winner = &w
+		}
+	default:
+		log.Fatalf("Unknown -search strategy %q", *search)
+	}
 	if winner == nil {
-		fmt.Println("❌ Part 2 has no winners!!! 😦")
-	} else {
-		fmt.Printf("✓ Part 2 found winner: %s in %s\n", winner, dur)
+		return "no winners"
+	}
+	if z, _ := p.Compute(*winner); z != 0 {
+		return fmt.Sprintf("%s gives z=%d", winner, z)
 	}
+	return winner.String()
 }
 
+// part1 returns the maximum valid input for the program.
+func part1(lines []string) string {
+	return solve(lines, 1)
+}
+
+// part2 returns the minimum valid input for the program.
+func part2(lines []string) string {
+	return solve(lines, 2)
+}
+
+var (
+	checkpointFile  = flag.String("checkpoint", "checkpoint.json", "file to save search progress, empty to disable; program suffix is added before the extension")
+	checkpointEvery = flag.Duration("checkpoint-every", time.Minute, "how often to write the checkpoint file")
+	resume          = flag.Bool("resume", false, "skip ranges recorded in the checkpoint file by a previous run")
+	search          = flag.String("search", "range", "search strategy: range or dp")
+)
+
 func main() {
-	if false {
-		exploratory()
-		os.Exit(0)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bench":
+			programMain("bench", os.Args[2:], benchmark)
+			return
+		case "explore":
+			programMain("explore", os.Args[2:], exploratory)
+			return
+		}
+	}
+	flag.IntVar(&numWorkers, "workers", numWorkers, "number of goroutines searching ranges")
+	runMain(part1, part2)
+}
+
+// programMain implements the bench and explore subcommands, which run f on the
+// generated code for each input file rather than solving the puzzle:
+// % go run day24.go runner.go input*.go bench input.actual.txt
+func programMain(name string, args []string, f func(p *Program)) {
+	log.SetFlags(log.Ltime)
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Parse(args)
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"} // read stdin
+	}
+	for _, fname := range files {
+		lines, err := readLines(fname)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p, err := lookupProgram(lines)
+		if err != nil {
+			log.Fatalf("%s: %v", fname, err)
+		}
+		f(p)
+	}
+}
+
+const dayName = "day24"
+
+// dpKey is a memoization key: the index of the next digit and the z value
+// produced by the stages before it.
+type dpKey struct{ digit, z int }
+
+// dpResult holds the largest and smallest digit suffixes which produce a final
+// z of 0 from a dpKey state, stored as base-10 integers.
+type dpResult struct {
+	ok       bool
+	max, min int
+}
+
+// dpSearcher finds the maximum and minimum valid inputs by searching digit
+// prefixes and memoizing the results for each (digit, z) pair, since stages
+// only communicate through z.  States with z too large to reach 0 in the
+// remaining stages are pruned: z can only shrink by dividing, so if the
+// remaining stages divide z by a product of at most limit[digit], any larger z
+// can't reach 0.
+type dpSearcher struct {
+	prog   *Program
+	memo   map[dpKey]dpResult
+	limit  [15]int
+	states int
+	pruned int
+}
+
+func newDPSearcher(p *Program) *dpSearcher {
+	d := &dpSearcher{prog: p, memo: make(map[dpKey]dpResult)}
+	d.limit[14] = 1
+	for i := 13; i >= 0; i-- {
+		div := p.DivZ[i]
+		if div == 0 || d.limit[i+1] == math.MaxInt || d.limit[i+1] > math.MaxInt/div {
+			d.limit[i] = math.MaxInt
+		} else {
+			d.limit[i] = d.limit[i+1] * div
+		}
 	}
-	// If running the program more than once was valuable, some state could
-	// perhaps be preserved from part1, but since my part 1 answer started with
-	// 99, not much work would be saved in part 2.
-	part1()
-	part2()
-	os.Exit(0)
+	return d
+}
+
+func (d *dpSearcher) solve(digit, z int) dpResult {
+	if digit == 14 {
+		return dpResult{ok: z == 0}
+	}
+	if z >= d.limit[digit] || z <= -d.limit[digit] {
+		d.pruned++
+		return dpResult{}
+	}
+	key := dpKey{digit: digit, z: z}
+	if res, ok := d.memo[key]; ok {
+		return res
+	}
+	d.states++
+	res := dpResult{}
+	place := int(math.Pow10(13 - digit))
+	for w := 9; w >= 1; w-- {
+		sub := d.solve(digit+1, d.prog.Stage(digit, z, w))
+		if !sub.ok {
+			continue
+		}
+		hi, lo := w*place+sub.max, w*place+sub.min
+		if !res.ok {
+			res = dpResult{ok: true, max: hi, min: lo}
+		} else {
+			res.max = max(res.max, hi)
+			res.min = min(res.min, lo)
+		}
+	}
+	d.memo[key] = res
+	return res
+}
+
+// digits converts a 14-digit base-10 number to an Input.
+func digits(n int) Input {
+	var v Input
+	for i := 13; i >= 0; i-- {
+		v[i] = n % 10
+		n /= 10
+	}
+	return v
+}
+
+// searchDP returns the maximum and minimum valid inputs found by dpSearcher.
+// The memoization is only sound if stages don't share w, x, or y.
+func searchDP(p *Program) dpResult {
+	if !p.StagesIndependent {
+		log.Fatalf("%s has stages which read w, x, or y before writing them, -search=dp would give wrong answers", p.Name)
+	}
+	d := newDPSearcher(p)
+	res := d.solve(0, 0)
+	log.Printf("Explored %d states, pruned %d, z limits %v", d.states, d.pruned, d.limit)
+	return res
+}
+
+// benchCount is the number of inputs evaluated by benchmark, the same as the
+// C program generated by genday24.go -c.
+const benchCount = 100000000
+
+// benchmark times p.Compute on the benchCount largest inputs for comparison
+// with the generated C program.
+func benchmark(p *Program) {
+	top := Input{9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9}.Int()
+	zeros := 0
+	start := time.Now()
+	for v := top; v > top-benchCount; v-- {
+		if z, _ := p.Compute(NewInput(v)); z == 0 {
+			zeros++
+		}
+	}
+	dur := time.Since(start)
+	log.Printf("%s: %d inputs, %d with z=0, in %s (%.0f/s)", p.Name, benchCount, zeros, dur, benchCount/dur.Seconds())
 }
 
 // exploratory is where I tried some things out to see what might be inferred
 // about changing individual digits.
-func exploratory() {
+func exploratory(p *Program) {
 	best := Input{9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9}
 	for i := 0; i < 14; i++ {
 		input := best
 		min := math.MaxInt
 		for j := 9; j > 0; j-- {
 			input[i] = j
-			z, zvals := Compute_inputactual(input)
+			z, zvals := p.Compute(input)
 			if z == 0 {
 				log.Printf("Got 0 z value from %v\nz vals %v\n", input, zvals)
 			}
@@ -275,15 +583,15 @@ func exploratory() {
 		}
 		log.Printf("Digit %d best %d partial %d", i, best[i], min)
 	}
-	zbest, _ := Compute_inputactual(best)
+	zbest, _ := p.Compute(best)
 	fmt.Printf("Best: %s gets %d\n", best, zbest)
 	tweak := best
 	for i := 0; i < 14; i++ {
-		min, _ := Compute_inputactual(tweak)
+		min, _ := p.Compute(tweak)
 		minj := best[i]
 		for j := 9; j > 0; j-- {
 			tweak[i] = j
-			z, zvals := Compute_inputactual(tweak)
+			z, zvals := p.Compute(tweak)
 			if z < min {
 				log.Printf("Digit %d = %d with %s got z = %d, better than %d\n%v\n", i, j, tweak, z, min, zvals)
 				min = z
@@ -292,6 +600,187 @@ func exploratory() {
 		}
 		tweak[i] = minj
 	}
-	ztweak, _ := Compute_inputactual(tweak)
+	ztweak, _ := p.Compute(tweak)
 	fmt.Printf("Tweaked: %s gets %d\n", tweak, ztweak)
 }
+
+// span is a range of absolute input values (as returned by Input.Int) which
+// are known not to produce z=0.
+type span struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+func (s span) String() string { return Range{min: s.Min, max: s.Max}.String() }
+
+// inFlight records a range being searched by a worker and the next value it
+// will check.  All values from next+1 through the end of the range have been
+// checked, in the signed value space of the search.
+type inFlight struct {
+	Min    int `json:"min"`
+	Max    int `json:"max"`
+	Factor int `json:"factor"`
+	Next   int `json:"next"`
+}
+
+// toSpan converts signed search values from..to (multiplied by factor to get
+// input values) to an absolute span.
+func toSpan(from, to, factor int) span {
+	r := MaybeNegativeRange(factor*from, factor*to)
+	return span{Min: r.min, Max: r.max}
+}
+
+// searchState is the content of a checkpoint file.  Completed spans are kept
+// sorted and merged when adjacent.  Since a completed span contains no valid
+// inputs, it's useful in both the maximum and minimum searches.
+type searchState struct {
+	mu        sync.Mutex
+	Completed []span     `json:"completed"`
+	InFlight  []inFlight `json:"inFlight"`
+	dirty     bool
+}
+
+// load reads a checkpoint file.  Progress made by workers which were in
+// flight when the file was written is treated as completed.
+func (c *searchState) load(fname string) error {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := json.Unmarshal(data, c); err != nil {
+		return err
+	}
+	sort.Slice(c.Completed, func(i, j int) bool { return c.Completed[i].Min < c.Completed[j].Min })
+	merged := c.Completed[:0]
+	for _, s := range c.Completed {
+		if n := len(merged); n > 0 && merged[n-1].Max+1 >= s.Min {
+			merged[n-1].Max = max(merged[n-1].Max, s.Max)
+		} else {
+			merged = append(merged, s)
+		}
+	}
+	c.Completed = merged
+	for _, f := range c.InFlight {
+		if f.Next < f.Max {
+			c.addCompleted(toSpan(f.Next+1, f.Max, f.Factor))
+		}
+	}
+	c.InFlight = nil
+	log.Printf("Resuming with %d completed spans from %s", len(c.Completed), fname)
+	return nil
+}
+
+// addCompleted inserts s into the sorted list of completed spans, merging it
+// with any spans it touches.  Caller must hold the lock.
+func (c *searchState) addCompleted(s span) {
+	c.dirty = true
+	i := sort.Search(len(c.Completed), func(i int) bool { return c.Completed[i].Max+1 >= s.Min })
+	j := i
+	for j < len(c.Completed) && c.Completed[j].Min <= s.Max+1 {
+		s.Min = min(s.Min, c.Completed[j].Min)
+		s.Max = max(s.Max, c.Completed[j].Max)
+		j++
+	}
+	c.Completed = append(c.Completed[:i], append([]span{s}, c.Completed[j:]...)...)
+}
+
+// progress records that a worker searching r has checked every value above
+// next.
+func (c *searchState) progress(r Range, factor, next int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirty = true
+	for i, f := range c.InFlight {
+		if f.Min == r.min && f.Max == r.max && f.Factor == factor {
+			c.InFlight[i].Next = next
+			return
+		}
+	}
+	c.InFlight = append(c.InFlight, inFlight{Min: r.min, Max: r.max, Factor: factor, Next: next})
+}
+
+// complete records that r contains no valid inputs.
+func (c *searchState) complete(r Range, factor int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, f := range c.InFlight {
+		if f.Min == r.min && f.Max == r.max && f.Factor == factor {
+			c.InFlight = append(c.InFlight[:i], c.InFlight[i+1:]...)
+			break
+		}
+	}
+	c.addCompleted(toSpan(r.min, r.max, factor))
+}
+
+// knownInvalid returns the completed spans which overlap signed search range
+// r, converted to signed values and sorted in descending order, the order in
+// which searchRange encounters them.
+func (c *searchState) knownInvalid(r Range, factor int) []Range {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]Range, 0)
+	for _, s := range c.Completed {
+		sr := MaybeNegativeRange(factor*s.Min, factor*s.Max)
+		if sr.max >= r.min && sr.min <= r.max {
+			res = append(res, sr)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].max > res[j].max })
+	return res
+}
+
+// save writes the checkpoint to fname if anything has changed since the last
+// save.  The file is written to a temporary name and renamed so an interrupted
+// write doesn't clobber the previous checkpoint.
+func (c *searchState) save(fname string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		log.Printf("Could not encode checkpoint: %v", err)
+		return
+	}
+	tmp := fname + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("Could not write checkpoint %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, fname); err != nil {
+		log.Printf("Could not rename checkpoint %s to %s: %v", tmp, fname, err)
+		return
+	}
+	c.dirty = false
+}
+
+// saveEvery starts a goroutine which saves the checkpoint to fname at each
+// interval.  The returned function stops the goroutine and does a final save.
+func (c *searchState) saveEvery(fname string, interval time.Duration) func() {
+	if fname == "" {
+		return func() {}
+	}
+	done := make(chan bool)
+	finished := make(chan bool)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.save(fname)
+			case <-done:
+				c.save(fname)
+				close(finished)
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
@@ -4,29 +4,122 @@
 // license that can be found in the LICENSE file or at
 // https://opensource.org/licenses/MIT.
 
+//go:build ignore
+
 // genday24 generates a Go function implementation of a 2021 Day 24 Advent of
-// Code input file.  day24.go calls this generated function.
+// Code input file.  The generated code registers itself with day24.go's
+// programs registry, keyed by a hash of the input, so any number of input files
+// can be compiled into the same package.  It also generates
+// a function which runs a single stage of the program (the instructions from
+// one inp to the next) and the value z is divided by in each stage, which
+// day24.go uses for a dynamic programming search.
+//
+// With -c, a standalone C program is also generated for speed comparisons.  It
+// prints z for each input given as a 14-digit argument, or runs a benchmark
+// over the same inputs as the day24.go bench subcommand if there are no arguments.
+//
+//	go run genday24.go [-c] input.actual.txt ...
 package main
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 )
 
 type Instruction struct{ Op, First, Second string }
-type Program struct {
-	Suffix       string
-	SrcFile      string
+
+// Stage is a sequence of instructions starting with inp.  DivZ is the literal
+// argument to a "div z" instruction in the stage, 1 if z isn't divided, or 0 if
+// z is divided by something other than a literal.
+type Stage struct {
 	Instructions []Instruction
+	DivZ         int
+}
+
+// independent reports whether the stage writes w, x, and y before reading
+// them, so its result only depends on the input digit and z.  "mul r 0" and
+// inp write r without reading it; any other instruction reads both operands.
+func (s Stage) independent() bool {
+	written := map[string]bool{"z": true}
+	for _, inst := range s.Instructions {
+		switch {
+		case inst.Op == "inp":
+		case inst.Op == "mul" && inst.Second == "0":
+		case !written[inst.First]:
+			return false
+		case strings.Contains("wxy", inst.Second) && !written[inst.Second]:
+			return false
+		}
+		written[inst.First] = true
+	}
+	return true
+}
+
+type Program struct {
+	Suffix            string
+	SrcFile           string
+	Name              string
+	Hash              string
+	Instructions      []Instruction
+	Stages            []Stage
+	StagesIndependent bool
+}
+
+// splitStages groups the program's instructions into stages starting with
+// each inp instruction and sets StagesIndependent if running each stage with
+// w, x, and y set to 0 matches the full program.  That holds for the first
+// stage if there are no instructions before the first inp, and for later
+// stages if they write w, x, and y before reading them.
+func (p *Program) splitStages() {
+	p.StagesIndependent = true
+	for _, inst := range p.Instructions {
+		if inst.Op == "inp" {
+			p.Stages = append(p.Stages, Stage{DivZ: 1})
+		}
+		if len(p.Stages) == 0 {
+			p.StagesIndependent = false
+			continue
+		}
+		s := &p.Stages[len(p.Stages)-1]
+		s.Instructions = append(s.Instructions, inst)
+		if inst.Op == "div" && inst.First == "z" {
+			if d, err := strconv.Atoi(inst.Second); err == nil && s.DivZ != 0 {
+				s.DivZ *= d
+			} else {
+				s.DivZ = 0
+			}
+		}
+	}
+	for i, s := range p.Stages {
+		if i > 0 && !s.independent() {
+			p.StagesIndependent = false
+		}
+	}
 }
 
 var tmpl = template.Must(template.New("program").Parse(
 	`// Code generated by go run ./genday24.go {{.SrcFile}}; DO NOT EDIT
 package main
 
+func init() {
+	registerProgram("{{.Hash}}", &Program{
+		Name:              "{{.Name}}",
+		Suffix:            "{{.Suffix}}",
+		Compute:           Compute_{{.Suffix}},
+		Stage:             Stage_{{.Suffix}},
+		DivZ:              DivZ_{{.Suffix}},
+		StagesIndependent: {{.StagesIndependent}},
+	})
+}
+
 func Compute_{{.Suffix}}(input [14]int) (int, [15]int) {
 	var i, w, x, y, z int
 	var zvals [15]int
@@ -55,10 +148,120 @@ func Compute_{{.Suffix}}(input [14]int) (int, [15]int) {
 	zvals[14] = z
 	return z, zvals
 }
+
+// Stage_{{.Suffix}} runs the instructions for one input digit, starting with
+// z from the previous stage and w, x, and y set to 0.  This only matches the
+// full program if StagesIndependent is true.
+func Stage_{{.Suffix}}(digit, z, in int) int {
+	var w, x, y int
+	switch digit {
+{{- range $i, $s := .Stages}}
+	case {{$i}}:
+{{- range $s.Instructions}}
+		// {{.Op}} {{.First}}{{if .Second}} {{.Second}}{{end}}
+{{- if eq .Op "inp"}}
+		{{.First}} = in
+{{- else if eq .Op "add"}}
+		{{.First}} += {{.Second}}
+{{- else if eq .Op "mul"}}
+		{{.First}} *= {{.Second}}
+{{- else if eq .Op "div"}}
+		{{.First}} /= {{.Second}}
+{{- else if eq .Op "mod"}}
+		{{.First}} %= {{.Second}}
+{{- else if eq .Op "eql"}}
+		if {{.First}} == {{.Second}} {
+			{{.First}} = 1
+		} else {
+			{{.First}} = 0
+		}
+{{- end}}
+{{- end}}
+{{- end}}
+	}
+	_, _, _ = w, x, y
+	return z
+}
+
+// DivZ_{{.Suffix}} is the amount z is divided by in each stage, or 0 if unknown.
+var DivZ_{{.Suffix}} = [14]int{ {{- range $i, $s := .Stages}}{{if $i}}, {{end}}{{$s.DivZ}}{{end -}} }
+`))
+
+var ctmpl = template.Must(template.New("cprogram").Parse(
+	`// Code generated by go run ./genday24.go -c {{.SrcFile}}; DO NOT EDIT
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <time.h>
+
+static long long compute(const int input[14]) {
+	long long w = 0, x = 0, y = 0, z = 0;
+	int i = 0;
+{{- range .Instructions}}
+	// {{.Op}} {{.First}}{{if .Second}} {{.Second}}{{end}}
+{{- if eq .Op "inp"}}
+	{{.First}} = input[i++];
+{{- else if eq .Op "add"}}
+	{{.First}} += {{.Second}};
+{{- else if eq .Op "mul"}}
+	{{.First}} *= {{.Second}};
+{{- else if eq .Op "div"}}
+	{{.First}} /= {{.Second}};
+{{- else if eq .Op "mod"}}
+	{{.First}} %= {{.Second}};
+{{- else if eq .Op "eql"}}
+	{{.First}} = {{.First}} == {{.Second}};
+{{- end}}
+{{- end}}
+	(void)w, (void)x, (void)y, (void)i;
+	return z;
+}
+
+// new_input converts val to base-9 digits 1 through 9, like NewInput in day24.go.
+static void new_input(long long val, int input[14]) {
+	for (int i = 13; i >= 0; i--) {
+		input[i] = (int)(val % 9) + 1;
+		val /= 9;
+	}
+}
+
+int main(int argc, char **argv) {
+	int input[14];
+	if (argc > 1) {
+		for (int a = 1; a < argc; a++) {
+			if (strlen(argv[a]) != 14) {
+				fprintf(stderr, "Expected 14 digits: %s\n", argv[a]);
+				return 1;
+			}
+			for (int i = 0; i < 14; i++) {
+				input[i] = argv[a][i] - '0';
+			}
+			printf("%s z=%lld\n", argv[a], compute(input));
+		}
+		return 0;
+	}
+	// Matches benchCount and Input{9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9}.Int() in day24.go
+	const long long count = 100000000;
+	const long long top = 22876792454960LL;
+	long long zeros = 0;
+	clock_t start = clock();
+	for (long long v = top; v > top - count; v--) {
+		new_input(v, input);
+		if (compute(input) == 0) {
+			zeros++;
+		}
+	}
+	double secs = (double)(clock() - start) / CLOCKS_PER_SEC;
+	printf("{{.Name}}: %lld inputs, %lld with z=0, in %.3fs (%.0f/s)\n", count, zeros, secs, count / secs);
+	return 0;
+}
 `))
 
+var emitC = flag.Bool("c", false, "also generate a standalone C program for each input")
+
 func main() {
-	for _, inname := range os.Args[1:] {
+	flag.Parse()
+	for _, inname := range flag.Args() {
 		outname := strings.ReplaceAll(strings.TrimSuffix(inname, ".txt"), ".", "") + ".go"
 		log.Printf("Generating %s from %s\n", outname, inname)
 		in, err := os.Open(inname)
@@ -74,12 +277,15 @@ func main() {
 		p := Program{
 			Suffix:       strings.TrimSuffix(outname, ".go"),
 			SrcFile:      inname,
+			Name:         filepath.Base(inname),
 			Instructions: make([]Instruction, 0),
 		}
 		s := bufio.NewScanner(in)
 		lineno := 0
+		lines := make([]string, 0)
 		for s.Scan() {
 			lineno++
+			lines = append(lines, s.Text())
 			line := strings.Split(s.Text(), " ")
 			if len(line) != 3 && (len(line) != 2 || line[0] != "inp") {
 				log.Fatalf("Unexpected instruction format %s on line %d", s.Text(), lineno)
@@ -90,6 +296,27 @@ func main() {
 			}
 			p.Instructions = append(p.Instructions, inst)
 		}
-		err = tmpl.Execute(out, p)
+		// day24.go's programHash must compute the same value.
+		sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+		p.Hash = hex.EncodeToString(sum[:])
+		p.splitStages()
+		if len(p.Stages) != 14 {
+			log.Fatalf("Expected 14 inp instructions in %s, got %d", inname, len(p.Stages))
+		}
+		if err = tmpl.Execute(out, p); err != nil {
+			log.Fatalf("Error writing %s: %v", outname, err)
+		}
+		if *emitC {
+			cname := p.Suffix + ".c"
+			log.Printf("Generating %s from %s\n", cname, inname)
+			cout, err := os.Create(cname)
+			if err != nil {
+				log.Fatalf("Could not open output file %s: %v", cname, err)
+			}
+			defer cout.Close()
+			if err = ctmpl.Execute(cout, p); err != nil {
+				log.Fatalf("Error writing %s: %v", cname, err)
+			}
+		}
 	}
 }
@@ -1,6 +1,17 @@
 // Code generated by go run ./genday24.go input.actual.txt; DO NOT EDIT
 package main
 
+func init() {
+	registerProgram("4d315282f6c9b16bfe0a2ec9bec31b6c9c1cb6a9ee6f91c9af31a590bb050cc5", &Program{
+		Name:              "input.actual.txt",
+		Suffix:            "inputactual",
+		Compute:           Compute_inputactual,
+		Stage:             Stage_inputactual,
+		DivZ:              DivZ_inputactual,
+		StagesIndependent: true,
+	})
+}
+
 func Compute_inputactual(input [14]int) (int, [15]int) {
 	var i, w, x, y, z int
 	var zvals [15]int
@@ -651,3 +662,647 @@ func Compute_inputactual(input [14]int) (int, [15]int) {
 	zvals[14] = z
 	return z, zvals
 }
+
+// Stage_inputactual runs the instructions for one input digit, starting with
+// z from the previous stage and w, x, and y set to 0.  This only matches the
+// full program if StagesIndependent is true.
+func Stage_inputactual(digit, z, in int) int {
+	var w, x, y int
+	switch digit {
+	case 0:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 1
+		z /= 1
+		// add x 12
+		x += 12
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 6
+		y += 6
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 1:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 1
+		z /= 1
+		// add x 10
+		x += 10
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 2
+		y += 2
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 2:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 1
+		z /= 1
+		// add x 10
+		x += 10
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 13
+		y += 13
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 3:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 26
+		z /= 26
+		// add x -6
+		x += -6
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 8
+		y += 8
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 4:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 1
+		z /= 1
+		// add x 11
+		x += 11
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 13
+		y += 13
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 5:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 26
+		z /= 26
+		// add x -12
+		x += -12
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 8
+		y += 8
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 6:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 1
+		z /= 1
+		// add x 11
+		x += 11
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 3
+		y += 3
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 7:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 1
+		z /= 1
+		// add x 12
+		x += 12
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 11
+		y += 11
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 8:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 1
+		z /= 1
+		// add x 12
+		x += 12
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 10
+		y += 10
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 9:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 26
+		z /= 26
+		// add x -2
+		x += -2
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 8
+		y += 8
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 10:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 26
+		z /= 26
+		// add x -5
+		x += -5
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 14
+		y += 14
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 11:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 26
+		z /= 26
+		// add x -4
+		x += -4
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 6
+		y += 6
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 12:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 26
+		z /= 26
+		// add x -4
+		x += -4
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 8
+		y += 8
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	case 13:
+		// inp w
+		w = in
+		// mul x 0
+		x *= 0
+		// add x z
+		x += z
+		// mod x 26
+		x %= 26
+		// div z 26
+		z /= 26
+		// add x -12
+		x += -12
+		// eql x w
+		if x == w {
+			x = 1
+		} else {
+			x = 0
+		}
+		// eql x 0
+		if x == 0 {
+			x = 1
+		} else {
+			x = 0
+		}
+		// mul y 0
+		y *= 0
+		// add y 25
+		y += 25
+		// mul y x
+		y *= x
+		// add y 1
+		y += 1
+		// mul z y
+		z *= y
+		// mul y 0
+		y *= 0
+		// add y w
+		y += w
+		// add y 2
+		y += 2
+		// mul y x
+		y *= x
+		// add z y
+		z += y
+	}
+	_, _, _ = w, x, y
+	return z
+}
+
+// DivZ_inputactual is the amount z is divided by in each stage, or 0 if unknown.
+var DivZ_inputactual = [14]int{1, 1, 1, 26, 1, 26, 1, 1, 1, 26, 26, 26, 26, 26}
@@ -0,0 +1 @@
+../../lang/go/circle.go
\ No newline at end of file
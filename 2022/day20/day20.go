@@ -10,83 +10,70 @@
 day20 in Go since I could not figure out what was wrong with my Elixir
 solution so I figured I'd reimplement it in a different language to see if I
 had a hard-to-spot bug.  Turns out it was an unclear specification.
-XXX This program implements the incorrect interpretation of the problem
-(tea party guests move but chairs stay put); day20.exs implements the correct
-interpretation (tea party guests carry their chair around the table).
+This program implements both interpretations of the problem.  By default it
+uses the correct one, like day20.exs: tea party guests carry their chair around
+the table, so a number moving n-1 places around a circle of n numbers passes
+every other number and ends up where it started.  With -semantics=stay it uses
+the incorrect one: guests move but chairs stay put, so a number counts its own
+old position while walking around the circle.  The two only differ for
+numbers whose magnitude is at least n-1; run with -diverge to see where.
 https://www.reddit.com/r/adventofcode/comments/zrggym/2022_day_20_alice_in_wonderland_explains_the_two/
+
+Moving a CircleNode walks the linked list, so each round of mixing is
+O(n^2).  Run with -mixer=treap to use an implicit treap instead, where finding
+a number's position, removing it, and inserting it elsewhere are each
+O(log n).
 */
 package main
 
 import (
+	"flag"
 	"log"
+	"math/rand"
 	"strconv"
+	"strings"
 )
 
-func main() {
-	runMain(part1, part2)
-}
-
-const dayName = "day20"
+var (
+	mixer     = flag.String("mixer", "list", "mixing implementation: list or treap")
+	semantics = flag.String("semantics", "carry", "mixing interpretation: carry (guests carry chairs) or stay (chairs stay put)")
+	diverge   = flag.Bool("diverge", false, "mix with both interpretations and report where they diverge")
+	key       = flag.Int("key", 811589153, "decryption key each number is multiplied by in part 2")
+	rounds    = flag.Int("rounds", 10, "number of times to mix in part 2")
+	offsets   = flag.String("offsets", "1000,2000,3000", "comma-separated positions after 0 to add up for the score")
+)
 
-type Node struct {
-	value      int
-	prev, next *Node
+// stepsCarry is the number of other numbers value passes when guests carry
+// their chairs: there are size-1 others, so a full lap is size-1 steps.
+func stepsCarry(value, size int) int {
+	return value % (size - 1)
 }
 
-func (n *Node) find(steps int) *Node {
-	o := n
-	if steps < 0 {
-		for i := steps; i < 0; i++ {
-			o = o.prev
-		}
-	} else {
-		for i := 0; i < steps; i++ {
-			o = o.next
-		}
-	}
-	return o
+// stepsStay is the number of other numbers value passes when chairs stay put:
+// a full lap is size chairs, including the number's own, and a partial lap of
+// k chairs passes k other numbers.
+func stepsStay(value, size int) int {
+	return value % size
 }
 
-func (n *Node) findValue(v int) *Node {
-	o := n
-	for o.value != v {
-		o = o.next
+// stepFunc returns the steps function for the -semantics flag.
+func stepFunc() func(value, size int) int {
+	switch *semantics {
+	case "carry":
+		return stepsCarry
+	case "stay":
+		return stepsStay
+	default:
+		log.Fatalf("Unknown semantics %q", *semantics)
+		return nil
 	}
-	return o
 }
 
-func (n *Node) move(steps int) {
-	if steps == 0 {
-		return
-	}
-	// Remove the current node from the circle first, due to unstated assumptions
-	// in the problem description.
-	n.prev.next = n.next
-	n.next.prev = n.prev
-	t := n.find(steps)
-	if steps < 0 {
-		prev := t.prev
-		n.prev = prev
-		n.next = t
-		t.prev = n
-		prev.next = n
-	} else {
-		next := t.next
-		n.next = next
-		n.prev = t
-		t.next = n
-		next.prev = n
-	}
+func main() {
+	runMain(part1, part2)
 }
 
-func (n *Node) toSlice() []int {
-	ints := make([]int, 0, 5000)
-	ints = append(ints, n.value)
-	for o := n.next; o != n; o = o.next {
-		ints = append(ints, o.value)
-	}
-	return ints
-}
+const dayName = "day20"
 
 func stringsToInts(strs []string) []int {
 	res := make([]int, len(strs))
@@ -101,50 +88,244 @@ func stringsToInts(strs []string) []int {
 }
 
 func part1(lines []string) string {
-	ints := stringsToInts(lines)
-	size := len(ints)
-	nodes := buildList(ints, 1)
-	for _, n := range nodes {
-		n.move(n.value % (size - 1))
+	return strconv.Itoa(mix(stringsToInts(lines), 1, 1))
+}
+
+func part2(lines []string) string {
+	return strconv.Itoa(mix(stringsToInts(lines), *key, *rounds))
+}
+
+// sampleOffsets parses the -offsets flag.
+func sampleOffsets() []int {
+	var res []int
+	for _, s := range strings.Split(*offsets, ",") {
+		o, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			log.Fatalf("Invalid offset %q in %q: %v", s, *offsets, err)
+		}
+		res = append(res, o)
 	}
-	return strconv.Itoa(score(nodes))
+	return res
 }
 
-const part2Multiplier = 811589153
+// score adds up the values at each -offsets position after 0.
+func score(c *Circle) int {
+	res := 0
+	for _, v := range c.Sample(0, sampleOffsets()) {
+		res += v
+	}
+	return res
+}
 
-func part2(lines []string) string {
-	ints := stringsToInts(lines)
+// mix multiplies each int, mixes them rounds times with the -mixer
+// implementation, and returns the score.
+func mix(ints []int, multiplier, rounds int) int {
+	if *diverge {
+		findDivergence(ints, multiplier, rounds)
+	}
+	switch *mixer {
+	case "list":
+		return mixList(ints, multiplier, rounds, stepFunc())
+	case "treap":
+		return mixTreap(ints, multiplier, rounds, stepFunc())
+	default:
+		log.Fatalf("Unknown mixer %q", *mixer)
+		return 0
+	}
+}
+
+func mixList(ints []int, multiplier, rounds int, steps func(value, size int) int) int {
+	c := NewCircle(ints, multiplier)
+	c.Mix(rounds, steps)
+	return score(c)
+}
+
+// findDivergence mixes two lists in lockstep, one with each interpretation,
+// and logs the first move after which the circles differ, how many moves
+// differ in the number of steps, and the score with each interpretation.
+// It returns the index of the first diverging move, counting from 0 across
+// rounds, or -1 if the circles never differ, and the number of moves whose
+// steps differ.
+func findDivergence(ints []int, multiplier, rounds int) (first, differ int) {
 	size := len(ints)
-	nodes := buildList(ints, part2Multiplier)
-	for i := 0; i < 10; i++ {
-		for _, n := range nodes {
-			steps := n.value % (size - 1)
-			n.move(steps)
+	carry := NewCircle(ints, multiplier)
+	stay := NewCircle(ints, multiplier)
+	first = -1
+	for r := 0; r < rounds; r++ {
+		for i := range ints {
+			c, s := carry.Node(i), stay.Node(i)
+			cs, ss := stepsCarry(c.Value, size), stepsStay(s.Value, size)
+			c.Move(cs)
+			s.Move(ss)
+			if cs != ss {
+				differ++
+				if first < 0 && !carry.Equal(stay) {
+					first = r*size + i
+					log.Printf("Diverged in round %d at input line %d: %d passes %d numbers carrying its chair but %d with chairs staying put",
+						r+1, i+1, c.Value, cs, ss)
+					log.Printf("carry: %v", carry.SliceFrom(0))
+					log.Printf("stay:  %v", stay.SliceFrom(0))
+				}
+			}
 		}
 	}
-	return strconv.Itoa(score(nodes))
+	if first < 0 {
+		log.Printf("Interpretations agree on all %d moves", rounds*size)
+	}
+	log.Printf("%d of %d moves differ in steps; carry scores %d, stay scores %d", differ, rounds*size, score(carry), score(stay))
+	return first, differ
 }
 
-func buildList(ints []int, multiplier int) []*Node {
-	size := len(ints)
-	nodes := make([]*Node, 0, size)
-	for _, i := range ints {
-		n := &Node{value: i * multiplier}
-		if len(nodes) > 0 {
-			n.prev = nodes[len(nodes)-1]
-			nodes[len(nodes)-1].next = n
+// treap is an implicit treap: a randomized balanced binary tree ordered by
+// position in the circle rather than by key, where each node knows the size of
+// its subtree.  Nodes are identified by their index in the input, and parent
+// links let a node find its own position.  Slices of int32 rather than
+// pointers keep a million-number circle compact.
+type treap struct {
+	left, right, parent, size []int32
+	priority                  []uint32
+	values                    []int
+	root                      int32
+}
+
+const nilNode = int32(-1)
+
+func newTreap(values []int) *treap {
+	n := len(values)
+	t := &treap{
+		left: make([]int32, n), right: make([]int32, n), parent: make([]int32, n), size: make([]int32, n),
+		priority: make([]uint32, n), values: values, root: nilNode,
+	}
+	rnd := rand.New(rand.NewSource(int64(n)))
+	for i := range values {
+		t.left[i], t.right[i], t.parent[i], t.size[i] = nilNode, nilNode, nilNode, 1
+		t.priority[i] = rnd.Uint32()
+		t.root = t.merge(t.root, int32(i))
+	}
+	return t
+}
+
+func (t *treap) sizeOf(x int32) int {
+	if x == nilNode {
+		return 0
+	}
+	return int(t.size[x])
+}
+
+// update recomputes x's size and points its children back at x.
+func (t *treap) update(x int32) {
+	t.size[x] = int32(1 + t.sizeOf(t.left[x]) + t.sizeOf(t.right[x]))
+	if l := t.left[x]; l != nilNode {
+		t.parent[l] = x
+	}
+	if r := t.right[x]; r != nilNode {
+		t.parent[r] = x
+	}
+}
+
+// split divides the tree rooted at x into the first k positions and the rest.
+func (t *treap) split(x int32, k int) (int32, int32) {
+	if x == nilNode {
+		return nilNode, nilNode
+	}
+	t.parent[x] = nilNode
+	if ls := t.sizeOf(t.left[x]); k <= ls {
+		l, r := t.split(t.left[x], k)
+		t.left[x] = r
+		t.update(x)
+		return l, x
+	} else {
+		l, r := t.split(t.right[x], k-ls-1)
+		t.right[x] = l
+		t.update(x)
+		return x, r
+	}
+}
+
+// merge joins two trees with every position in a before every position in b.
+func (t *treap) merge(a, b int32) int32 {
+	if a == nilNode {
+		return b
+	}
+	if b == nilNode {
+		return a
+	}
+	if t.priority[a] > t.priority[b] {
+		t.right[a] = t.merge(t.right[a], b)
+		t.parent[a] = nilNode
+		t.update(a)
+		return a
+	}
+	t.left[b] = t.merge(a, t.left[b])
+	t.parent[b] = nilNode
+	t.update(b)
+	return b
+}
+
+// index returns the position of node x.
+func (t *treap) index(x int32) int {
+	i := t.sizeOf(t.left[x])
+	for p := t.parent[x]; p != nilNode; x, p = p, t.parent[p] {
+		if t.right[p] == x {
+			i += t.sizeOf(t.left[p]) + 1
+		}
+	}
+	return i
+}
+
+// at returns the node at position i.
+func (t *treap) at(i int) int32 {
+	x := t.root
+	for {
+		ls := t.sizeOf(t.left[x])
+		switch {
+		case i < ls:
+			x = t.left[x]
+		case i == ls:
+			return x
+		default:
+			i -= ls + 1
+			x = t.right[x]
 		}
-		nodes = append(nodes, n)
 	}
-	nodes[0].prev = nodes[size-1]
-	nodes[size-1].next = nodes[0]
-	return nodes
 }
 
-func score(nodes []*Node) int {
-	zero := nodes[0].findValue(0)
-	one := zero.find(1000)
-	two := one.find(1000)
-	three := two.find(2000)
-	return one.value + two.value + three.value
+// move removes node x from its position and moves it steps places around the
+// circle of the remaining nodes.
+func (t *treap) move(x int32, steps int) {
+	n := len(t.values)
+	i := t.index(x)
+	a, rest := t.split(t.root, i)
+	_, c := t.split(rest, 1)
+	t.root = t.merge(a, c)
+	j := ((i+steps)%(n-1) + (n - 1)) % (n - 1)
+	a, c = t.split(t.root, j)
+	t.left[x], t.right[x], t.size[x] = nilNode, nilNode, 1
+	t.root = t.merge(t.merge(a, x), c)
+}
+
+func mixTreap(ints []int, multiplier, rounds int, steps func(value, size int) int) int {
+	values := make([]int, len(ints))
+	zero := int32(-1)
+	for i, v := range ints {
+		values[i] = v * multiplier
+		if v == 0 {
+			zero = int32(i)
+		}
+	}
+	if zero < 0 {
+		log.Fatalf("No 0 in input")
+	}
+	t := newTreap(values)
+	for r := 0; r < rounds; r++ {
+		for x := range values {
+			t.move(int32(x), steps(values[x], len(values)))
+		}
+	}
+	z := t.index(zero)
+	res := 0
+	for _, offset := range sampleOffsets() {
+		res += values[t.at(((z+offset)%len(values)+len(values))%len(values))]
+	}
+	return res
 }
@@ -0,0 +1,49 @@
+// Copyright 2025 Trevor Stone
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file or at
+// https://opensource.org/licenses/MIT.
+
+// Run with
+// % go test day20_test.go day20.go circle.go runner.go
+package main
+
+import "testing"
+
+var example = []int{1, 2, -3, 3, -2, 0, 4}
+
+func TestSemanticsAgreeOnExample(t *testing.T) {
+	if first, differ := findDivergence(example, 1, 1); first != -1 || differ != 0 {
+		t.Errorf("findDivergence(example) = %d, %d; want -1, 0", first, differ)
+	}
+	carry := mixList(example, 1, 1, stepsCarry)
+	stay := mixList(example, 1, 1, stepsStay)
+	if carry != 3 || stay != 3 {
+		t.Errorf("example scores carry=%d stay=%d, want 3", carry, stay)
+	}
+}
+
+func TestSemanticsDiverge(t *testing.T) {
+	// 7 is at least n-1 in a circle of 7: carrying its chair it passes one
+	// number, but with chairs staying put it comes back to its own chair.
+	ints := []int{0, 1, 7, 2, 3, 4, 5}
+	if got := stepsCarry(7, len(ints)); got != 1 {
+		t.Errorf("stepsCarry(7, 7) = %d, want 1", got)
+	}
+	if got := stepsStay(7, len(ints)); got != 0 {
+		t.Errorf("stepsStay(7, 7) = %d, want 0", got)
+	}
+	if first, differ := findDivergence(ints, 1, 1); first != 2 || differ != 1 {
+		t.Errorf("findDivergence(%v) = %d, %d; want 2, 1", ints, first, differ)
+	}
+}
+
+func TestMixersAgree(t *testing.T) {
+	for _, steps := range []func(value, size int) int{stepsCarry, stepsStay} {
+		list := mixList(example, 811589153, 10, steps)
+		treap := mixTreap(example, 811589153, 10, steps)
+		if list != treap {
+			t.Errorf("list mixer scored %d but treap scored %d", list, treap)
+		}
+	}
+}
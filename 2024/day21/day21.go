@@ -15,172 +15,646 @@
 // button sequence that needs to be pressed on a user-controlled keypad which
 // controls N layers of directional keypad indirection and then finally the
 // numeric keypad.  Part 1 has 2 layers of indirection between the first and
-// last keypads, part 2 has 25 layers of indirection.
+// last keypads, part 2 has 25 layers of indirection.  Lengths grow about 2.5
+// times per layer, so -levels above 40 or so switch to big integers.  For very
+// deep chains, -method=matrix takes O(log levels) big matrix multiplications
+// rather than visiting every layer once the cheapest paths provably stop
+// changing; at a million levels the answer has about 400,000 digits, so big
+// integer arithmetic dominates.
+//
+// The keypads are generated from layout diagrams, and input may replace them
+// with custom layouts: a door keypad diagram, optionally followed by a
+// directional keypad diagram, each followed by a blank line, then the codes.
+// Every shortest path which avoids the gap is a candidate; the solver picks the
+// cheapest one at each level.  Run with -trace to log one optimal sequence at
+// each layer and check it by simulating the robot chain.
 
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"math"
+	"math/big"
+	"slices"
 	"strconv"
 	"strings"
 )
 
-type pad map[rune]map[rune][]string
+// pad is a keypad layout.  paths maps each pair of keys to every shortest
+// sequence of directional presses which moves from the first to the second
+// without crossing a gap, followed by A to push the second.
+type pad struct {
+	keys  map[position]rune
+	where map[rune]position
+	paths map[rune]map[rune][]string
+}
+
+const (
+	// numpadLayout is the door keypad; _ is a gap.
+	numpadLayout = `789
+456
+123
+_0A`
+	// dirpadLayout is the directional keypad robots are controlled with.
+	dirpadLayout = `_^A
+<v>`
+	gap = '_'
+)
+
+var (
+	trace      = flag.Bool("trace", false, "log and verify an optimal press sequence at each layer")
+	traceLimit = flag.Int("trace-limit", 10000, "maximum human presses to reconstruct with -trace")
+	levels     = flag.Int("levels", 25, "layers of robot-controlled directional keypads in part 2")
+	method     = flag.String("method", "memo", "how to compute lengths: memo (recursive with a cache) or matrix (repeated squaring)")
+)
+
+type direction struct {
+	row, col int
+	key      rune
+}
+type position struct{ row, col int }
 
 var (
-	numpad = pad{
-		'A': {
-			'7': {"^^^<<A", "<^^^<A"}, '8': {"^^^<A", "<^^^A"}, '9': {"^^^A"},
-			'4': {"^^<<A"}, '5': {"^^<A", "<^^A"}, '6': {"^^A"},
-			'1': {"^<<A"}, '2': {"^<A", "^<A"}, '3': {"^A"},
-			'0': {"<A"}, 'A': {"A"},
-		},
-		'0': {
-			'7': {"^^^<A", "^^^<A"}, '8': {"^^^A"}, '9': {"^^^>A", ">^^^A"},
-			'4': {"^^<A"}, '5': {"^^A"}, '6': {"^^>A", ">^^A"},
-			'1': {"^<A", "^<A"}, '2': {"^A"}, '3': {"^>A", ">^A"},
-			'0': {"A"}, 'A': {">A"},
-		},
-		'1': {
-			'7': {"^^A"}, '8': {"^^>A", ">^^A"}, '9': {"^^>>A", ">>^^A"},
-			'4': {"^A"}, '5': {"^>A", ">^A"}, '6': {"^>>A", ">>^A"},
-			'1': {"A"}, '2': {">A"}, '3': {">>A"},
-			'0': {">vA"}, 'A': {">>vA"},
-		},
-		'2': {
-			'7': {"^^<A", "<^^A"}, '8': {"^^A"}, '9': {"^^>A", ">^^A"},
-			'4': {"^<A", "<^A"}, '5': {"^A"}, '6': {"^>A", ">^A"},
-			'1': {"<A"}, '2': {"A"}, '3': {">A"},
-			'0': {"vA"}, 'A': {">vA", "v>A"},
-		},
-		'3': {
-			'7': {"^^<<A", "<<^^A"}, '8': {"^^<A", "<^^A"}, '9': {"^^A"},
-			'4': {"^<<A", "<<^A"}, '5': {"^<A", "<^A"}, '6': {"^A"},
-			'1': {"<<A"}, '2': {"<A"}, '3': {"A"},
-			'0': {"<vA", "v<A"}, 'A': {"vA"},
-		},
-		'4': {
-			'7': {"^A"}, '8': {"^>A", ">^A"}, '9': {"^>>A", ">>^A"},
-			'4': {"A"}, '5': {">A"}, '6': {">>A"},
-			'1': {"vA"}, '2': {">vA", "v>A"}, '3': {">>vA", "v>>A"},
-			'0': {">vvA"}, 'A': {">>vvA"},
-		},
-		'5': {
-			'7': {"^<A", ">^A"}, '8': {"^A"}, '9': {"^>A", "v^A"},
-			'4': {"<A"}, '5': {"A"}, '6': {">A"},
-			'1': {"<vA", "v<A"}, '2': {"vA"}, '3': {">vA", "v>A"},
-			'0': {"vvA"}, 'A': {">vvA", "vv>A"},
-		},
-		'6': {
-			'7': {"^<<A", "<<^A"}, '8': {"^<A", "<^A"}, '9': {"^A"},
-			'4': {"<<A"}, '5': {"<A"}, '6': {"A"},
-			'1': {"<<vA", "v<<A"}, '2': {"<vA", "<vA"}, '3': {"vA"},
-			'0': {"<vvA", "vv<A"}, 'A': {"vvA"},
-		},
-		'7': {
-			'7': {"A"}, '8': {">A"}, '9': {">>A"},
-			'4': {"vA"}, '5': {">vA", "v>A"}, '6': {">>vA", "v>>A"},
-			'1': {"vvA"}, '2': {">vvA", "vv>A"}, '3': {">>vvA", "vv>>A"},
-			'0': {">vvvA"}, 'A': {">>vvvA"},
-		},
-		'8': {
-			'7': {"<A"}, '8': {"A"}, '9': {">A"},
-			'4': {"<vA", "v<A"}, '5': {"vA"}, '6': {">vA", "v>A"},
-			'1': {"<vvA", "vv<A"}, '2': {"vvA"}, '3': {">vvA", "vvA"},
-			'0': {"vvvA"}, 'A': {">vvvA", "vvv>A"},
-		},
-		'9': {
-			'7': {"<<A"}, '8': {"<A"}, '9': {"A"},
-			'4': {"<<vA", "v<<A"}, '5': {"<vA", "v<A"}, '6': {"vA"},
-			'1': {"<<vvA", "vv<<A"}, '2': {"<vvA", "vv<A"}, '3': {"vvA"},
-			'0': {"<vvvA", "vvv<A"}, 'A': {"vvvA"},
-		},
-	}
-
-	dirpad = pad{
-		'A': {
-			'^': {"<A"}, 'A': {"A"},
-			'<': {"<v<A", "v<<A"}, 'v': {"<vA", "v<A"}, '>': {"vA"},
-		},
-		'^': {
-			'^': {"A"}, 'A': {">A"},
-			'<': {"v<A"}, 'v': {"vA"}, '>': {"v>A", ">vA"},
-		},
-		'<': {
-			'^': {">^A"}, 'A': {">>^A", ">^>A"},
-			'<': {"A"}, 'v': {">A"}, '>': {">>A"},
-		},
-		'v': {
-			'^': {"^A"}, 'A': {">^A", "^>A"},
-			'<': {"<A"}, 'v': {"A"}, '>': {">A"},
-		},
-		'>': {
-			'^': {"^<A", "<^A"}, 'A': {"^A"},
-			'<': {"<<A"}, 'v': {"<A"}, '>': {"A"},
-		},
-	}
-
-	allpads = []pad{numpad, dirpad, dirpad}
+	directions = []direction{{-1, 0, '^'}, {1, 0, 'v'}, {0, -1, '<'}, {0, 1, '>'}}
+
+	numpad = parsePad(strings.Split(numpadLayout, "\n"))
+	dirpad = parsePad(strings.Split(dirpadLayout, "\n"))
 )
 
+// parsePad reads a keypad layout diagram, one line per row of keys.  Spaces,
+// underscores, and positions past the end of a short line are gaps.
+func parsePad(lines []string) pad {
+	keys := make(map[position]rune)
+	where := make(map[rune]position)
+	for row, l := range lines {
+		for col, r := range []rune(l) {
+			if r == gap || r == ' ' {
+				continue
+			}
+			if _, dupe := where[r]; dupe {
+				log.Fatalf("Duplicate key %q in keypad %q", r, lines)
+			}
+			keys[position{row, col}] = r
+			where[r] = position{row, col}
+		}
+	}
+	if _, ok := where['A']; !ok {
+		log.Fatalf("Keypad %q has no A key to start from", lines)
+	}
+	p := pad{keys: keys, where: where, paths: make(map[rune]map[rune][]string)}
+	for from, fp := range where {
+		p.paths[from] = make(map[rune][]string)
+		for to, tp := range where {
+			p.paths[from][to] = shortestPaths(keys, fp, tp)
+		}
+	}
+	return p
+}
+
+// shortestPaths returns every sequence of moves which goes from one key to
+// another in the fewest presses, never moving over a gap, with A appended.
+func shortestPaths(keys map[position]rune, from, to position) []string {
+	if from == to {
+		return []string{"A"}
+	}
+	var res []string
+	for _, d := range directions {
+		if d.row*(to.row-from.row) <= 0 && d.col*(to.col-from.col) <= 0 {
+			continue // not closer
+		}
+		next := position{from.row + d.row, from.col + d.col}
+		if _, ok := keys[next]; !ok {
+			continue
+		}
+		for _, rest := range shortestPaths(keys, next, to) {
+			res = append(res, string(d.key)+rest)
+		}
+	}
+	return res
+}
+
+// validate checks that p can enter every key in seq.
+func (p pad) validate(seq string) {
+	for _, r := range seq {
+		if _, ok := p.where[r]; !ok {
+			log.Fatalf("Key %q in %q is not on the keypad", r, seq)
+		}
+	}
+}
+
 type key struct {
 	seq   string
 	depth int
 }
 
+// solver computes the fewest human presses to enter sequences.  Lengths are
+// ints until they overflow, after which the affected sequences fall back to
+// big.Int; cache holds -1 for a length which overflowed.
 type solver struct {
-	pads  []pad
-	cache map[key]int
+	pads     []pad
+	cache    map[key]int
+	bigCache map[key]*big.Int
 }
 
-// indirection is the number of robot-controlled dirpads
-func newSolver(indirection int) *solver {
-	pads := []pad{numpad}
+// newSolver returns a solver for entering codes on door through indirection
+// levels of robot-controlled robot keypads.
+func newSolver(door, robot pad, indirection int) *solver {
+	if indirection > 0 {
+		for _, d := range directions {
+			robot.validate(string(d.key) + "A")
+		}
+	}
+	pads := []pad{door}
 	for range indirection {
-		pads = append(pads, dirpad)
+		pads = append(pads, robot)
 	}
-	return &solver{pads: pads, cache: make(map[key]int)}
+	return &solver{pads: pads, cache: make(map[key]int), bigCache: make(map[key]*big.Int)}
 }
 
-func (s *solver) sequenceLength(seq string, depth int) int {
+// sequenceLength returns the number of human presses needed for the keypad at
+// depth to enter seq, and false if that number doesn't fit in an int.
+func (s *solver) sequenceLength(seq string, depth int) (int, bool) {
 	if depth >= len(s.pads) {
-		return len(seq)
+		return len(seq), true
 	}
 	k := key{seq, depth}
-	if l := s.cache[k]; l != 0 {
-		return l
+	if l, ok := s.cache[k]; ok {
+		return l, l >= 0
 	}
 	var total int
 	p := s.pads[depth]
 	cur := 'A'
 	for _, r := range seq {
-		l := math.MaxInt
-		for _, o := range p[cur][r] {
-			l = min(l, s.sequenceLength(o, depth+1))
+		l := -1
+		for _, o := range p.paths[cur][r] {
+			if ol, ok := s.sequenceLength(o, depth+1); ok && (l < 0 || ol < l) {
+				l = ol
+			}
+		}
+		if l < 0 || total > math.MaxInt-l {
+			s.cache[k] = -1
+			return 0, false
 		}
 		total += l
 		cur = r
 	}
 	s.cache[k] = total
+	return total, true
+}
+
+// bigSequenceLength is sequenceLength for lengths of any size.
+func (s *solver) bigSequenceLength(seq string, depth int) *big.Int {
+	if l, ok := s.sequenceLength(seq, depth); ok {
+		return big.NewInt(int64(l))
+	}
+	k := key{seq, depth}
+	if l, ok := s.bigCache[k]; ok {
+		return l
+	}
+	total := new(big.Int)
+	p := s.pads[depth]
+	cur := 'A'
+	for _, r := range seq {
+		var l *big.Int
+		for _, o := range p.paths[cur][r] {
+			if ol := s.bigSequenceLength(o, depth+1); l == nil || ol.Cmp(l) < 0 {
+				l = ol
+			}
+		}
+		total.Add(total, l)
+		cur = r
+	}
+	s.bigCache[k] = total
 	return total
 }
 
-func (s *solver) score(nums string) int {
-	i, err := strconv.Atoi(strings.ReplaceAll(nums, "A", ""))
+// expand returns an optimal sequence of presses on the keypad which controls
+// the one at depth, such that the keypad at depth enters seq.
+func (s *solver) expand(seq string, depth int) string {
+	var sb strings.Builder
+	p := s.pads[depth]
+	cur := 'A'
+	for _, r := range seq {
+		best, bestLen := "", math.MaxInt
+		for _, o := range p.paths[cur][r] {
+			if l, ok := s.sequenceLength(o, depth+1); ok && l < bestLen {
+				best, bestLen = o, l
+			}
+		}
+		sb.WriteString(best)
+		cur = r
+	}
+	return sb.String()
+}
+
+// sequences returns one optimal press sequence at each layer: the code on the
+// door keypad first, then what each robot's keypad enters, then the human's
+// presses last.
+func (s *solver) sequences(code string) []string {
+	layers := []string{code}
+	for depth := range s.pads {
+		layers = append(layers, s.expand(layers[depth], depth))
+	}
+	return layers
+}
+
+// simulate presses buttons on the human's keypad and returns what each keypad
+// in the chain enters, in the same order as sequences.  Robot arms start on A
+// and it is an error for an arm to point at a gap.
+func (s *solver) simulate(presses string) ([]string, error) {
+	layers := make([]string, len(s.pads)+1)
+	layers[len(s.pads)] = presses
+	for depth := len(s.pads) - 1; depth >= 0; depth-- {
+		p := s.pads[depth]
+		pos := p.where['A']
+		var sb strings.Builder
+		for i, r := range layers[depth+1] {
+			if r == 'A' {
+				sb.WriteRune(p.keys[pos])
+				continue
+			}
+			d := slices.IndexFunc(directions, func(d direction) bool { return d.key == r })
+			if d < 0 {
+				return nil, fmt.Errorf("layer %d press %d: %q is not a direction", depth+1, i, r)
+			}
+			pos = position{pos.row + directions[d].row, pos.col + directions[d].col}
+			if _, ok := p.keys[pos]; !ok {
+				return nil, fmt.Errorf("layer %d press %d: robot arm moved to gap %v", depth+1, i, pos)
+			}
+		}
+		layers[depth] = sb.String()
+	}
+	return layers, nil
+}
+
+// traceCode logs an optimal press sequence at each layer for code and checks
+// that simulating the human's presses through the robot chain enters it.
+func (s *solver) traceCode(code string) {
+	want, ok := s.sequenceLength(code, 0)
+	if !ok || want > *traceLimit {
+		log.Printf("%s: not tracing %s presses, limit is %d", code, s.bigSequenceLength(code, 0), *traceLimit)
+		return
+	}
+	layers := s.sequences(code)
+	for i, l := range layers {
+		log.Printf("%s layer %d (%d): %s", code, i, len(l), l)
+	}
+	sim, err := s.simulate(layers[len(layers)-1])
+	if err != nil {
+		log.Fatalf("%s: simulation failed: %v", code, err)
+	}
+	if len(layers[len(layers)-1]) != want {
+		log.Fatalf("%s: reconstructed %d presses, want %d", code, len(layers[len(layers)-1]), want)
+	}
+	for i := range layers {
+		if sim[i] != layers[i] {
+			log.Fatalf("%s: layer %d simulated %q, reconstructed %q", code, i, sim[i], layers[i])
+		}
+	}
+}
+
+// codeNumber returns the number formed by the digits in code.
+func codeNumber(code string) int {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, code)
+	i, err := strconv.Atoi(digits)
 	if err != nil {
-		log.Fatalf("Invalid numeric keypad entry %q", nums)
+		log.Fatalf("Invalid numeric keypad entry %q", code)
+	}
+	return i
+}
+
+// score is the sequence length for code times the number formed by its digits,
+// and false if that doesn't fit in an int.
+func (s *solver) score(code string) (int, bool) {
+	s.pads[0].validate(code)
+	i := codeNumber(code)
+	l, ok := s.sequenceLength(code, 0)
+	if !ok || (i != 0 && l > math.MaxInt/i) {
+		return 0, false
+	}
+	return l * i, true
+}
+
+// bigScore is score for lengths of any size.
+func (s *solver) bigScore(code string) *big.Int {
+	s.pads[0].validate(code)
+	return new(big.Int).Mul(s.bigSequenceLength(code, 0), big.NewInt(int64(codeNumber(code))))
+}
+
+// parseInput returns the door keypad, robot keypad, and codes.  Input is
+// usually just codes, but may start with a door keypad layout and then a robot
+// keypad layout, each followed by a blank line.
+func parseInput(lines []string) (door, robot pad, codes []string) {
+	var sections [][]string
+	start := 0
+	for i, l := range lines {
+		if l == "" {
+			sections = append(sections, lines[start:i])
+			start = i + 1
+		}
+	}
+	sections = append(sections, lines[start:])
+	door, robot = numpad, dirpad
+	switch len(sections) {
+	case 1:
+	case 2:
+		door = parsePad(sections[0])
+	case 3:
+		door, robot = parsePad(sections[0]), parsePad(sections[1])
+	default:
+		log.Fatalf("Expected at most two keypad layouts before the codes, got %d sections", len(sections))
 	}
-	return s.sequenceLength(nums, 0) * i
+	return door, robot, sections[len(sections)-1]
 }
 
 func solve(lines []string, levels int) string {
-	s := newSolver(levels)
+	door, robot, codes := parseInput(lines)
+	switch *method {
+	case "memo":
+	case "matrix":
+		if *trace {
+			log.Fatalf("-trace is not supported with -method=matrix")
+		}
+		m := newMatrixSolver(door, robot, levels)
+		total := new(big.Int)
+		for _, l := range codes {
+			door.validate(l)
+			total.Add(total, new(big.Int).Mul(m.sequenceLength(l), big.NewInt(int64(codeNumber(l)))))
+		}
+		return total.String()
+	default:
+		log.Fatalf("Unknown method %q", *method)
+	}
+	s := newSolver(door, robot, levels)
 	var total int
-	for _, l := range lines {
-		total += s.score(l)
+	overflow := false
+	for _, l := range codes {
+		if *trace {
+			s.traceCode(l)
+		}
+		score, ok := s.score(l)
+		if !ok || total > math.MaxInt-score {
+			overflow = true
+			break
+		}
+		total += score
+	}
+	if !overflow {
+		return strconv.Itoa(total)
+	}
+	bigTotal := new(big.Int)
+	for _, l := range codes {
+		bigTotal.Add(bigTotal, s.bigScore(l))
+	}
+	return bigTotal.String()
+}
+
+// matrixSolver computes lengths for very deep robot chains.  Let cost[k][x][y]
+// be the human presses needed for the robot keypad k layers below the human to
+// move from x to y and push y.  The human pushes keys directly, so cost[0] is
+// all 1s, and cost[k+1][x][y] is the cheapest candidate path from x to y
+// summed over cost[k] of each consecutive pair of keys in A + path.  Each
+// layer sums several pair costs, so lengths grow geometrically and the map
+// isn't min-plus linear.  But once the cheapest path for each pair stops
+// changing, each layer is the same linear map from pair costs to pair costs:
+// matrix entry [xy][pq] counts pq in the path chosen for xy.  Applying the map
+// d times is then a matrix power, computed by repeated squaring.  Layers are
+// computed directly until stable can prove the chosen paths stay cheapest.
+type matrixSolver struct {
+	door, robot pad
+	keys        []rune       // robot keys, indexing pairs as x*len(keys)+y
+	cost        []*big.Int   // cost of each robot pair at the bottom layer
+	index       map[rune]int // position of each key in keys
+}
+
+func newMatrixSolver(door, robot pad, indirection int) *matrixSolver {
+	m := &matrixSolver{door: door, robot: robot, index: make(map[rune]int)}
+	for k := range robot.where {
+		m.keys = append(m.keys, k)
+	}
+	slices.Sort(m.keys)
+	for i, k := range m.keys {
+		m.index[k] = i
+	}
+	n := len(m.keys)
+	m.cost = make([]*big.Int, n*n)
+	for i := range m.cost {
+		m.cost[i] = big.NewInt(1)
+	}
+	for level := 0; level < indirection; level++ {
+		next, choice := m.step(m.cost)
+		mat := newBigMatrix(n * n)
+		for xy, path := range choice {
+			for _, pq := range m.pairs(path) {
+				mat[xy][pq].Add(mat[xy][pq], big.NewInt(1))
+			}
+		}
+		if m.stable(mat, choice) {
+			if verbose {
+				log.Printf("Cheapest paths are stable after %d levels", level)
+			}
+			m.cost = mat.applyPower(indirection-level, m.cost)
+			return m
+		}
+		m.cost = next
+	}
+	return m
+}
+
+// pairs returns the pair index of each consecutive pair of keys in A + seq.
+func (m *matrixSolver) pairs(seq string) []int {
+	res := make([]int, 0, len(seq))
+	cur := 'A'
+	for _, r := range seq {
+		res = append(res, m.index[cur]*len(m.keys)+m.index[r])
+		cur = r
+	}
+	return res
+}
+
+// step returns the pair costs one layer further from the human, and the
+// cheapest path chosen for each pair, preferring earlier paths on ties.
+func (m *matrixSolver) step(cost []*big.Int) ([]*big.Int, []string) {
+	next := make([]*big.Int, len(cost))
+	choice := make([]string, len(cost))
+	for xy := range cost {
+		x, y := m.keys[xy/len(m.keys)], m.keys[xy%len(m.keys)]
+		for _, o := range m.robot.paths[x][y] {
+			c := new(big.Int)
+			for _, pq := range m.pairs(o) {
+				c.Add(c, cost[pq])
+			}
+			if next[xy] == nil || c.Cmp(next[xy]) < 0 {
+				next[xy], choice[xy] = c, o
+			}
+		}
+		if next[xy] == nil {
+			log.Fatalf("No path from %q to %q on the robot keypad", x, y)
+		}
+	}
+	return next, choice
+}
+
+// stableSteps is the number of deeper layers stable looks at before giving up
+// until the next layer.
+const stableSteps = 64
+
+// stable returns true if choice, the cheapest path for each pair given m.cost,
+// is still cheapest at every deeper layer, where mat is the map from one
+// layer's pair costs to the next with those choices.  For each other candidate
+// path, let d count its key pairs minus those of the chosen path, so d times
+// the cost vector is how much more the candidate costs.  The candidate costs
+// d*mat^i*cost more i layers deeper if choice holds until then, which is
+// checked directly for each i until d*mat^i has no negative entries.  Then
+// every deeper layer is at least as costly since mat and costs aren't
+// negative.  This is sufficient but not necessary, so callers keep computing
+// layers directly until it holds.
+func (m *matrixSolver) stable(mat bigMatrix, choice []string) bool {
+	for xy := range choice {
+		x, y := m.keys[xy/len(m.keys)], m.keys[xy%len(m.keys)]
+		for _, o := range m.robot.paths[x][y] {
+			if o == choice[xy] {
+				continue
+			}
+			d := make([]*big.Int, len(m.cost))
+			for i := range d {
+				d[i] = new(big.Int)
+			}
+			for _, pq := range m.pairs(o) {
+				d[pq].Add(d[pq], big.NewInt(1))
+			}
+			for _, pq := range m.pairs(choice[xy]) {
+				d[pq].Sub(d[pq], big.NewInt(1))
+			}
+			if !m.staysNonNegative(mat, d) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// staysNonNegative returns true if d*mat^i*m.cost is at least 0 for every i,
+// checking up to stableSteps layers for d*mat^i to lose its negative entries.
+func (m *matrixSolver) staysNonNegative(mat bigMatrix, d []*big.Int) bool {
+	var t big.Int
+	for range stableSteps {
+		if !slices.ContainsFunc(d, func(x *big.Int) bool { return x.Sign() < 0 }) {
+			return true
+		}
+		dot := new(big.Int)
+		for i, x := range d {
+			dot.Add(dot, t.Mul(x, m.cost[i]))
+		}
+		if dot.Sign() < 0 {
+			return false
+		}
+		d = mat.applyLeft(d)
+	}
+	return false
+}
+
+// sequenceLength returns the human presses needed to enter code on the door.
+func (m *matrixSolver) sequenceLength(code string) *big.Int {
+	total := new(big.Int)
+	cur := 'A'
+	for _, r := range code {
+		var best *big.Int
+		for _, o := range m.door.paths[cur][r] {
+			c := new(big.Int)
+			for _, pq := range m.pairs(o) {
+				c.Add(c, m.cost[pq])
+			}
+			if best == nil || c.Cmp(best) < 0 {
+				best = c
+			}
+		}
+		total.Add(total, best)
+		cur = r
+	}
+	return total
+}
+
+// bigMatrix is a square matrix of big integers.
+type bigMatrix [][]*big.Int
+
+func newBigMatrix(n int) bigMatrix {
+	mat := make(bigMatrix, n)
+	for i := range mat {
+		mat[i] = make([]*big.Int, n)
+		for j := range mat[i] {
+			mat[i][j] = new(big.Int)
+		}
+	}
+	return mat
+}
+
+func (a bigMatrix) multiply(b bigMatrix) bigMatrix {
+	res := newBigMatrix(len(a))
+	var t big.Int
+	for i := range a {
+		for k, aik := range a[i] {
+			if aik.Sign() == 0 {
+				continue
+			}
+			for j, bkj := range b[k] {
+				res[i][j].Add(res[i][j], t.Mul(aik, bkj))
+			}
+		}
+	}
+	return res
+}
+
+// applyPower returns a to the nth power times the column vector v, squaring a
+// repeatedly and applying each square that's part of n.
+func (a bigMatrix) applyPower(n int, v []*big.Int) []*big.Int {
+	for sq := a; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			v = sq.apply(v)
+		}
+		if n > 1 {
+			sq = sq.multiply(sq)
+		}
+	}
+	return v
+}
+
+// applyLeft returns the row vector v times a.
+func (a bigMatrix) applyLeft(v []*big.Int) []*big.Int {
+	res := make([]*big.Int, len(a))
+	for j := range res {
+		res[j] = new(big.Int)
+	}
+	var t big.Int
+	for i, vi := range v {
+		if vi.Sign() == 0 {
+			continue
+		}
+		for j, aij := range a[i] {
+			res[j].Add(res[j], t.Mul(vi, aij))
+		}
+	}
+	return res
+}
+
+// apply returns a times the column vector v.
+func (a bigMatrix) apply(v []*big.Int) []*big.Int {
+	res := make([]*big.Int, len(a))
+	var t big.Int
+	for i := range a {
+		res[i] = new(big.Int)
+		for j, aij := range a[i] {
+			res[i].Add(res[i], t.Mul(aij, v[j]))
+		}
 	}
-	return strconv.Itoa(total)
+	return res
 }
 
 func part1(lines []string) string {
@@ -188,7 +662,7 @@ func part1(lines []string) string {
 }
 
 func part2(lines []string) string {
-	return solve(lines, 25)
+	return solve(lines, *levels)
 }
 
 func main() {
@@ -1,4 +1,4 @@
-//usr/bin/true; exec /usr/bin/env go run "$0" "`dirname $0`/runner.go" "$@"
+//usr/bin/true; exec /usr/bin/env go run "$0" "`dirname $0`/runner.go" "`dirname $0`/intset.go" "$@"
 // Copyright 2024 Google LLC
 //
 // Use of this source code is governed by an MIT-style
@@ -9,161 +9,547 @@
 //
 // Input is lines like ab-cd indicating an undirected connection between two
 // computers in a network.  Part 1 answer is the number of groups of three
-// fully-connected computers where at least one computer name starts with 't'.
+// fully-connected computers where at least one computer name starts with 't'
+// (or the -prefix flag).
 // Part 2 answer is the sorted, comma-separated list of computers which form the
 // largest fully-connected subcomponent of the network.
+//
+// By default part 2 uses Bron–Kerbosch with pivoting over bitsets of computer
+// indices, which visits every maximal clique; -clique=queue uses the original
+// approach of shrinking candidate sets one computer at a time.  Part 1 counts
+// groups of -k computers, which is only 3 with the queue approach.
+//
+// The graph subcommand exports the network as Graphviz DOT, GraphML, or
+// adjacency JSON and prints some statistics; see graphMain.
 
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"maps"
+	"os"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
-type stringset map[string]bool
+var (
+	cliqueMethod = flag.String("clique", "bk", "clique algorithm: bk (Bron–Kerbosch) or queue")
+	cliqueSize   = flag.Int("k", 3, "size of the groups to count in part 1")
+	listCliques  = flag.Bool("list-cliques", false, "log every maximal clique in part 2 (bk only)")
+	part1Prefix  = flag.String("prefix", "t", "part 1 counts groups with a computer name starting with this")
+)
 
-func (s stringset) add(v string) stringset {
-	s[v] = true
-	return s
+type setqueue struct {
+	sets    [][]Bitset
+	biggest int
 }
 
-func (s stringset) remove(v string) stringset {
-	delete(s, v)
-	return s
+func (q *setqueue) add(s Bitset) {
+	size := s.Count()
+	for size >= len(q.sets) {
+		q.sets = append(q.sets, nil)
+		q.biggest = size
+	}
+	q.sets[size] = append(q.sets[size], s)
 }
 
-func (s stringset) contains(v string) bool { return s[v] }
-
-func (s stringset) intersect(o stringset) stringset {
-	r := make(stringset)
-	for v := range s {
-		if o.contains(v) {
-			r.add(v)
+func (q *setqueue) pop() Bitset {
+	r := q.sets[q.biggest][0]
+	q.sets[q.biggest] = q.sets[q.biggest][1:]
+	for len(q.sets[q.biggest]) == 0 {
+		q.biggest--
+		if q.biggest <= 0 {
+			log.Fatalf("sequeue is empty: %v", q)
 		}
 	}
 	return r
 }
 
-func (s stringset) tokey() string {
-	return strings.Join(slices.Sorted(maps.Keys(s)), ",")
+func fullyConnected(g *graph, s Bitset) bool {
+	connected := true
+	s.Each(func(k int) {
+		o := s.Minus(g.adj[k])
+		o.Clear(k)
+		connected = connected && o.IsEmpty()
+	})
+	return connected
 }
 
-type setqueue struct {
-	sets    [][]stringset
-	biggest int
+// graph is the network with computers numbered in name order.
+type graph struct {
+	names *Interner[string]
+	adj   []Bitset
 }
 
-func (q *setqueue) add(s stringset) {
-	for len(s) >= len(q.sets) {
-		q.sets = append(q.sets, nil)
-		q.biggest = len(s)
+// all returns the set of every computer.
+func (g *graph) all() Bitset {
+	all := NewBitset(g.names.Len())
+	for i := range g.names.Len() {
+		all.Set(i)
 	}
-	q.sets[len(s)] = append(q.sets[len(s)], s)
+	return all
 }
 
-func (q *setqueue) pop() stringset {
-	r := q.sets[q.biggest][0]
-	q.sets[q.biggest] = q.sets[q.biggest][1:]
-	for len(q.sets[q.biggest]) == 0 {
-		q.biggest--
-		if q.biggest <= 0 {
-			log.Fatalf("sequeue is empty: %v", q)
+func (g *graph) key(clique []int) string {
+	names := make([]string, len(clique))
+	for i, c := range clique {
+		names[i] = g.names.Value(c)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ",")
+}
+
+// maximalCliques calls visit with each maximal clique, using Bron–Kerbosch
+// with pivoting.  visit must not retain the slice.
+func (g *graph) maximalCliques(visit func(clique []int)) {
+	var bk func(r []int, p, x Bitset)
+	bk = func(r []int, p, x Bitset) {
+		if p.IsEmpty() && x.IsEmpty() {
+			visit(r)
+			return
+		}
+		// Neighbors of the pivot will be in some clique with the pivot or
+		// with a non-neighbor, so only branch on non-neighbors.
+		pivot, most := -1, -1
+		pick := func(u int) {
+			if c := p.IntersectCount(g.adj[u]); c > most {
+				pivot, most = u, c
+			}
 		}
+		p.Each(pick)
+		x.Each(pick)
+		p.Minus(g.adj[pivot]).Each(func(v int) {
+			bk(append(r, v), p.Intersect(g.adj[v]), x.Intersect(g.adj[v]))
+			p.Clear(v)
+			x.Set(v)
+		})
 	}
-	return r
+	bk(nil, g.all(), NewBitset(g.names.Len()))
 }
 
-func twokey(a, b string) string {
-	return min(a, b) + "," + max(b, a)
+// maxClique returns the largest clique, preferring the alphabetically first
+// key if there's a tie.
+func (g *graph) maxClique() []int {
+	var best []int
+	var bestKey string
+	g.maximalCliques(func(c []int) {
+		if *listCliques {
+			log.Printf("Maximal clique of %d: %s", len(c), g.key(c))
+		}
+		if len(c) > len(best) || (len(c) == len(best) && g.key(c) < bestKey) {
+			best, bestKey = slices.Clone(c), g.key(c)
+		}
+	})
+	slices.Sort(best)
+	return best
 }
 
-func fullyConnected(comps map[string]stringset, s stringset) bool {
-	for k := range maps.Keys(s) {
-		c := comps[k]
-		for x := range maps.Keys(s) {
-			if x != k && !c.contains(x) {
-				return false
+// countCliques returns the number of k-computer cliques with at least one name
+// starting with prefix.  Cliques are built in ascending index order so each is
+// counted once.
+func (g *graph) countCliques(k int, prefix string) int {
+	var count func(size int, cand Bitset, matched bool) int
+	count = func(size int, cand Bitset, matched bool) int {
+		if size == k {
+			if matched {
+				return 1
 			}
+			return 0
 		}
+		total := 0
+		cand.Each(func(v int) {
+			next := g.adj[v].Intersect(cand)
+			for i := 0; i <= v; i++ {
+				next.Clear(i)
+			}
+			total += count(size+1, next, matched || strings.HasPrefix(g.names.Value(v), prefix))
+		})
+		return total
 	}
-	return true
+	return count(0, g.all(), false)
 }
 
 func part1(lines []string) string {
-	comps := makeComputers(lines)
+	g := makeComputers(lines)
+	switch *cliqueMethod {
+	case "bk":
+		return strconv.Itoa(g.countCliques(*cliqueSize, *part1Prefix))
+	case "queue":
+		if *cliqueSize != 3 {
+			log.Fatalf("-clique=queue only counts groups of 3, not %d", *cliqueSize)
+		}
+	default:
+		log.Fatalf("Unknown clique method %q", *cliqueMethod)
+	}
 	seen := make(map[string]bool)
-	for a, s := range comps {
-		if a[0] == 't' {
-			for b := range maps.Keys(s) {
-				for c := range maps.Keys(comps[b]) {
-					if s.contains(c) {
-						seen[make(stringset).add(a).add(b).add(c).tokey()] = true
+	for a, s := range g.adj {
+		if strings.HasPrefix(g.names.Value(a), *part1Prefix) {
+			s.Each(func(b int) {
+				g.adj[b].Each(func(c int) {
+					if s.Has(c) {
+						t := NewBitset(g.names.Len())
+						t.Set(a)
+						t.Set(b)
+						t.Set(c)
+						seen[t.Key()] = true
 					}
-				}
-			}
+				})
+			})
 		}
 	}
 	return strconv.Itoa(len(seen))
 }
 
 func part2(lines []string) string {
-	comps := makeComputers(lines)
+	g := makeComputers(lines)
+	switch *cliqueMethod {
+	case "bk":
+		return g.key(g.maxClique())
+	case "queue":
+	default:
+		log.Fatalf("Unknown clique method %q", *cliqueMethod)
+	}
 	var pq setqueue
 	seen := make(map[string]bool)
-	for k, c := range comps {
-		for v := range maps.Keys(c) {
-			kk := twokey(k, v)
-			if !seen[kk] {
+	for k, c := range g.adj {
+		c.Each(func(v int) {
+			s := c.Intersect(g.adj[v])
+			s.Set(k)
+			s.Set(v)
+			if kk := s.Key(); !seen[kk] {
 				seen[kk] = true
-				s := c.intersect(comps[v])
-				s.add(k).add(v)
 				pq.add(s)
 			}
-		}
+		})
 	}
-	// for i := 0; i <= pq.biggest; i++ {
-	// 	log.Printf("PQ %d has %d", i, len(pq.sets[i]))
-	// }
 	for {
 		s := pq.pop()
-		if fullyConnected(comps, s) {
-			return s.tokey()
+		if fullyConnected(g, s) {
+			return g.key(s.Members())
 		}
-		for k := range maps.Keys(s) {
-			o := maps.Clone(s).remove(k)
-			kk := o.tokey()
-			if !seen[kk] {
+		s.Each(func(k int) {
+			o := s.Clone()
+			o.Clear(k)
+			if kk := o.Key(); !seen[kk] {
 				seen[kk] = true
 				pq.add(o)
 			}
-		}
+		})
 	}
 }
 
-func makeComputers(lines []string) map[string]stringset {
-	comps := make(map[string]stringset)
-	for _, l := range lines {
+// parseEdges returns the connections in lines.  Every malformed line and
+// self-connection is reported in the error, with line numbers.  Repeated
+// connections, in either order, are logged and skipped.
+func parseEdges(lines []string) ([][2]string, error) {
+	var edges [][2]string
+	var errs []error
+	seen := make(map[[2]string]int)
+	for i, l := range lines {
 		a, b, found := strings.Cut(l, "-")
-		if !found {
-			log.Fatalf("Invalid input line %q", l)
-		}
-		if comps[a] == nil {
-			comps[a] = make(stringset)
-		}
-		if comps[b] == nil {
-			comps[b] = make(stringset)
+		switch {
+		case !found:
+			errs = append(errs, fmt.Errorf("line %d: %q is not two computers separated by -", i+1, l))
+		case !validName(a) || !validName(b):
+			errs = append(errs, fmt.Errorf("line %d: %q has an empty or invalid computer name", i+1, l))
+		case a == b:
+			errs = append(errs, fmt.Errorf("line %d: %q connects %s to itself", i+1, l, a))
+		default:
+			e := [2]string{min(a, b), max(a, b)}
+			if prev, dupe := seen[e]; dupe {
+				log.Printf("line %d: %q repeats the connection on line %d, skipping", i+1, l, prev)
+				continue
+			}
+			seen[e] = i + 1
+			edges = append(edges, e)
 		}
-		comps[a].add(b)
-		comps[b].add(a)
 	}
-	return comps
+	return edges, errors.Join(errs...)
+}
+
+// validName returns true if s is a non-empty computer name without spaces or
+// dashes.
+func validName(s string) bool {
+	return s != "" && !strings.ContainsFunc(s, func(r rune) bool { return r == '-' || unicode.IsSpace(r) })
+}
+
+// makeComputers returns the network, with computers numbered in name order.
+func makeComputers(lines []string) *graph {
+	edges, err := parseEdges(lines)
+	if err != nil {
+		log.Fatalf("Invalid input:\n%v", err)
+	}
+	names := make(map[string]bool)
+	for _, e := range edges {
+		names[e[0]], names[e[1]] = true, true
+	}
+	g := &graph{names: NewInterner[string]()}
+	for _, n := range slices.Sorted(maps.Keys(names)) {
+		g.names.ID(n)
+	}
+	g.adj = make([]Bitset, g.names.Len())
+	for i := range g.adj {
+		g.adj[i] = NewBitset(g.names.Len())
+	}
+	for _, e := range edges {
+		a, _ := g.names.Lookup(e[0])
+		b, _ := g.names.Lookup(e[1])
+		g.adj[a].Set(b)
+		g.adj[b].Set(a)
+	}
+	return g
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "graph" {
+		graphMain(os.Args[2:])
+		return
+	}
 	runMain(part1, part2)
 }
 
+// graphMain implements the graph subcommand, which exports each input network
+// and prints statistics about it rather than solving the puzzle:
+// % go run day23.go runner.go graph -export lan.dot,lan.json input.actual.txt
+// % dot -Tsvg lan.dot > lan.svg
+func graphMain(args []string) {
+	log.SetFlags(log.Ltime)
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	export := fs.String("export", "", "comma-separated files to write the graph to, format based on extension: .dot, .graphml, or .json")
+	stats := fs.Bool("stats", true, "print degree distribution, connected components, and triangle counts")
+	fs.Parse(args)
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"} // read stdin
+	}
+	exportCount := make(map[string]int)
+	for _, fname := range files {
+		lines, err := readLines(fname)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g := makeComputers(lines)
+		if *stats {
+			fmt.Printf("%s:\n", fname)
+			g.printStats(os.Stdout)
+		}
+		if *export == "" {
+			continue
+		}
+		clique := g.maxClique()
+		for _, out := range strings.Split(*export, ",") {
+			ext := filepath.Ext(out)
+			base := strings.TrimSuffix(out, ext)
+			if n := exportCount[out]; n > 0 {
+				base += fmt.Sprintf(".%d", n)
+			}
+			exportCount[out]++
+			g.exportFile(base+ext, ext, clique)
+		}
+	}
+}
+
+// edges returns each connection once, lower index first.
+func (g *graph) edges() [][2]int {
+	var res [][2]int
+	for i, a := range g.adj {
+		a.Each(func(j int) {
+			if i < j {
+				res = append(res, [2]int{i, j})
+			}
+		})
+	}
+	return res
+}
+
+// components returns the connected components, largest first.
+func (g *graph) components() [][]int {
+	seen := NewBitset(g.names.Len())
+	var res [][]int
+	for start := range g.names.Len() {
+		if seen.Has(start) {
+			continue
+		}
+		comp := []int{start}
+		seen.Set(start)
+		for i := 0; i < len(comp); i++ {
+			g.adj[comp[i]].Each(func(j int) {
+				if !seen.Has(j) {
+					seen.Set(j)
+					comp = append(comp, j)
+				}
+			})
+		}
+		res = append(res, comp)
+	}
+	slices.SortStableFunc(res, func(a, b []int) int { return len(b) - len(a) })
+	return res
+}
+
+// triangles returns the number of triangles each computer is part of.
+func (g *graph) triangles() []int {
+	res := make([]int, g.names.Len())
+	for i, a := range g.adj {
+		a.Each(func(j int) {
+			res[i] += a.IntersectCount(g.adj[j])
+		})
+		res[i] /= 2 // each triangle is seen from both other corners
+	}
+	return res
+}
+
+// histogram returns "value: count" lines for each distinct value in vals.
+func histogram(vals []int) []string {
+	counts := make(map[int]int)
+	for _, v := range vals {
+		counts[v]++
+	}
+	var res []string
+	for _, v := range slices.Sorted(maps.Keys(counts)) {
+		res = append(res, fmt.Sprintf("%d: %d", v, counts[v]))
+	}
+	return res
+}
+
+func (g *graph) printStats(w io.Writer) {
+	fmt.Fprintf(w, "computers: %d\nconnections: %d\n", g.names.Len(), len(g.edges()))
+	degrees := make([]int, g.names.Len())
+	for i, a := range g.adj {
+		degrees[i] = a.Count()
+	}
+	fmt.Fprintf(w, "degree distribution (degree: computers):\n")
+	for _, h := range histogram(degrees) {
+		fmt.Fprintf(w, "  %s\n", h)
+	}
+	comps := g.components()
+	sizes := make([]string, len(comps))
+	for i, c := range comps {
+		sizes[i] = strconv.Itoa(len(c))
+	}
+	fmt.Fprintf(w, "connected components: %d, sizes %s\n", len(comps), strings.Join(sizes, ","))
+	tri := g.triangles()
+	total := 0
+	for _, t := range tri {
+		total += t
+	}
+	fmt.Fprintf(w, "triangles: %d\n", total/3)
+	fmt.Fprintf(w, "triangle distribution (triangles: computers):\n")
+	for _, h := range histogram(tri) {
+		fmt.Fprintf(w, "  %s\n", h)
+	}
+	fmt.Fprintf(w, "maximum clique: %s\n", g.key(g.maxClique()))
+}
+
+// exportFile writes the graph to fname in the format for ext, highlighting
+// the computers and connections in clique.
+func (g *graph) exportFile(fname, ext string, clique []int) {
+	out, err := os.Create(fname)
+	if err != nil {
+		log.Fatalf("Could not create %s: %v", fname, err)
+	}
+	inClique := NewBitset(g.names.Len())
+	for _, c := range clique {
+		inClique.Set(c)
+	}
+	w := bufio.NewWriter(out)
+	switch ext {
+	case ".dot":
+		g.writeDOT(w, inClique)
+	case ".graphml":
+		g.writeGraphML(w, inClique)
+	case ".json":
+		err = g.writeJSON(w)
+	default:
+		log.Fatalf("Unknown export format %q for %s, want .dot, .graphml, or .json", ext, fname)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		log.Fatalf("Error writing %s: %v", fname, err)
+	}
+	log.Printf("Wrote %d computers to %s", g.names.Len(), fname)
+}
+
+// writeDOT writes an undirected Graphviz graph with the clique filled in and
+// its connections drawn thick and red.
+func (g *graph) writeDOT(w io.Writer, inClique Bitset) {
+	fmt.Fprintln(w, "graph lan {")
+	fmt.Fprintln(w, "  node [shape=circle];")
+	for i := range g.names.Len() {
+		n := g.names.Value(i)
+		if inClique.Has(i) {
+			fmt.Fprintf(w, "  %q [style=filled, fillcolor=gold];\n", n)
+		} else {
+			fmt.Fprintf(w, "  %q;\n", n)
+		}
+	}
+	for _, e := range g.edges() {
+		if inClique.Has(e[0]) && inClique.Has(e[1]) {
+			fmt.Fprintf(w, "  %q -- %q [color=red, penwidth=2];\n", g.names.Value(e[0]), g.names.Value(e[1]))
+		} else {
+			fmt.Fprintf(w, "  %q -- %q;\n", g.names.Value(e[0]), g.names.Value(e[1]))
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// writeGraphML writes an undirected GraphML graph with a boolean clique
+// attribute on nodes and edges.
+func (g *graph) writeGraphML(w io.Writer, inClique Bitset) {
+	esc := func(s string) string {
+		var sb strings.Builder
+		xml.EscapeText(&sb, []byte(s))
+		return sb.String()
+	}
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(w, `  <key id="clique" for="all" attr.name="clique" attr.type="boolean"><default>false</default></key>`)
+	fmt.Fprintln(w, `  <graph id="lan" edgedefault="undirected">`)
+	for i := range g.names.Len() {
+		n := g.names.Value(i)
+		if inClique.Has(i) {
+			fmt.Fprintf(w, "    <node id=\"%s\"><data key=\"clique\">true</data></node>\n", esc(n))
+		} else {
+			fmt.Fprintf(w, "    <node id=\"%s\"/>\n", esc(n))
+		}
+	}
+	for _, e := range g.edges() {
+		a, b := esc(g.names.Value(e[0])), esc(g.names.Value(e[1]))
+		if inClique.Has(e[0]) && inClique.Has(e[1]) {
+			fmt.Fprintf(w, "    <edge source=\"%s\" target=\"%s\"><data key=\"clique\">true</data></edge>\n", a, b)
+		} else {
+			fmt.Fprintf(w, "    <edge source=\"%s\" target=\"%s\"/>\n", a, b)
+		}
+	}
+	fmt.Fprintln(w, "  </graph>")
+	fmt.Fprintln(w, "</graphml>")
+}
+
+// writeJSON writes an object mapping each computer to its sorted neighbors.
+func (g *graph) writeJSON(w io.Writer) error {
+	adj := make(map[string][]string, g.names.Len())
+	for i := range g.names.Len() {
+		n := g.names.Value(i)
+		adj[n] = []string{}
+		g.adj[i].Each(func(j int) { adj[n] = append(adj[n], g.names.Value(j)) })
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(adj)
+}
+
 const dayName = "day23"
@@ -0,0 +1 @@
+../../lang/go/intset.go
\ No newline at end of file
@@ -16,17 +16,33 @@
 // the minimum number of button presses to reach the desired pattern.
 // In part 2, buttons increase the joltage level at each given index, the
 // answer is the minimum number of button presses to reach the desired levels.
+//
+// Part 2 is an integer linear program: minimize the sum of presses x subject
+// to Ax = joltage, x >= 0, where A[i][j] is 1 if button j increases joltage i.
+// By default it's solved by branch and bound, using an exact simplex over
+// big.Rat for the linear relaxation and the dual simplex to re-optimize it
+// after each branch.  -solver=reduce uses the original approach of reducing
+// the matrix and searching over free variables, which can't handle more than
+// 13 buttons.
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"maps"
+	"math/big"
 	"math/bits"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 )
 
+var solver = flag.String("solver", "ilp", "part 2 solver: ilp (branch and bound over exact simplex) or reduce")
+
+// machine stores lights and buttons as bitmasks, so parseMachine rejects
+// machines with more than bits.UintSize lights, buttons, or counters.
 type machine struct {
 	desired uint
 	buttons []uint
@@ -38,6 +54,9 @@ func parseMachine(line string) machine {
 	var m machine
 	words := strings.Fields(line)
 	first := words[0]
+	if len(first)-2 > bits.UintSize {
+		log.Fatalf("Too many lights in %s, at most %d are supported", first, bits.UintSize)
+	}
 	for i, c := range first[1 : len(first)-1] {
 		if c == '#' {
 			m.desired |= 1 << i
@@ -46,6 +65,9 @@ func parseMachine(line string) machine {
 	for _, w := range words[1 : len(words)-1] {
 		m.buttons = append(m.buttons, parseButton(w))
 	}
+	if len(m.buttons) > bits.UintSize {
+		log.Fatalf("Too many buttons in %s, at most %d are supported", line, bits.UintSize)
+	}
 	last := words[len(words)-1]
 	for _, s := range strings.Split(last[1:len(last)-1], ",") {
 		i, err := strconv.Atoi(s)
@@ -54,6 +76,9 @@ func parseMachine(line string) machine {
 		}
 		m.joltage = append(m.joltage, i)
 	}
+	if len(m.joltage) > bits.UintSize {
+		log.Fatalf("Too many joltage counters in %s, at most %d are supported", last, bits.UintSize)
+	}
 	return m
 }
 
@@ -64,6 +89,9 @@ func parseButton(word string) uint {
 		if err != nil {
 			log.Fatalf("Non-integer button in %s", word)
 		}
+		if i < 0 || i >= bits.UintSize {
+			log.Fatalf("Button index %d in %s is outside 0 to %d", i, word, bits.UintSize-1)
+		}
 		b |= 1 << i
 	}
 	return b
@@ -405,7 +433,15 @@ func part2(lines []string) string {
 	for _, m := range machines {
 		// log.Printf("%d: %d buttons %d joltages %d total joltage", m.num, len(m.buttons), len(m.joltage), sumSlice(m.joltage))
 		// start := time.Now()
-		x := machinePart2(m)
+		var x int
+		switch *solver {
+		case "ilp":
+			x = machineILP(m)
+		case "reduce":
+			x = machinePart2(m)
+		default:
+			log.Fatalf("Unknown solver %q", *solver)
+		}
 		// dur := time.Now().Sub(start)
 		// log.Printf("%d: best %d after %s", m.num, x, dur)
 		sum += x
@@ -416,6 +452,536 @@ func part2(lines []string) string {
 	return strconv.Itoa(sum)
 }
 
+// constraint is a linear constraint coeffs·x sense rhs, where sense is -1 for
+// <=, 0 for =, and 1 for >=.
+type constraint struct {
+	coeffs []*big.Rat
+	sense  int
+	rhs    *big.Rat
+}
+
+// tableau is a simplex tableau for minimizing cost·x subject to constraints
+// and x >= 0.  Each row is an equality over all columns, with the right hand
+// side in the last column, and basis[i] is the column which is basic in row i.
+// The objective row holds reduced costs, with minus the objective value in the
+// last column.
+type tableau struct {
+	rows       [][]*big.Rat
+	obj        []*big.Rat
+	basis      []int
+	artificial int // columns from this to the right hand side are artificial
+	// upper and lower map a variable to the bound added by addBound.
+	upper, lower map[int]bound
+}
+
+// bound is a constraint x[j] <= value or x[j] >= value, with a slack column
+// that's only in that constraint's row.
+type bound struct {
+	slack int
+	value *big.Rat
+}
+
+var ratZero = new(big.Rat)
+
+// newTableau converts constraints over n variables to equalities by adding a
+// slack or surplus column for each inequality, then adds an artificial column
+// for each row so that there's an obvious starting basis.
+func newTableau(n int, cons []constraint) *tableau {
+	slacks := 0
+	for _, c := range cons {
+		if c.sense != 0 {
+			slacks++
+		}
+	}
+	cols := n + slacks + len(cons)
+	t := &tableau{artificial: n + slacks}
+	slack := n
+	for i, c := range cons {
+		row := make([]*big.Rat, cols+1)
+		for j := range row {
+			row[j] = new(big.Rat)
+		}
+		for j, v := range c.coeffs {
+			row[j].Set(v)
+		}
+		row[cols].Set(c.rhs)
+		if c.sense != 0 {
+			row[slack].SetInt64(int64(-c.sense))
+			slack++
+		}
+		if row[cols].Sign() < 0 {
+			for _, v := range row {
+				v.Neg(v)
+			}
+		}
+		row[t.artificial+i].SetInt64(1)
+		t.rows = append(t.rows, row)
+		t.basis = append(t.basis, t.artificial+i)
+	}
+	return t
+}
+
+// clone returns a deep copy of t, so a branch can add constraints to it.
+func (t *tableau) clone() *tableau {
+	c := &tableau{rows: make([][]*big.Rat, len(t.rows)), obj: cloneRats(t.obj),
+		basis: slices.Clone(t.basis), artificial: t.artificial,
+		upper: maps.Clone(t.upper), lower: maps.Clone(t.lower)}
+	for i, row := range t.rows {
+		c.rows[i] = cloneRats(row)
+	}
+	return c
+}
+
+func cloneRats(r []*big.Rat) []*big.Rat {
+	res := make([]*big.Rat, len(r))
+	for i, v := range r {
+		res[i] = new(big.Rat).Set(v)
+	}
+	return res
+}
+
+// setObjective sets the objective row to cost, a coefficient for each column,
+// then expresses it in terms of the non-basic columns.
+func (t *tableau) setObjective(cost []*big.Rat) {
+	width := len(t.rows[0])
+	t.obj = make([]*big.Rat, width)
+	for j := range t.obj {
+		t.obj[j] = new(big.Rat)
+		if j < len(cost) {
+			t.obj[j].Set(cost[j])
+		}
+	}
+	var tmp big.Rat
+	for i, b := range t.basis {
+		if f := new(big.Rat).Set(t.obj[b]); f.Sign() != 0 {
+			for j, v := range t.rows[i] {
+				t.obj[j].Sub(t.obj[j], tmp.Mul(f, v))
+			}
+		}
+	}
+}
+
+func (t *tableau) pivot(r, c int) {
+	inv := new(big.Rat).Inv(t.rows[r][c])
+	var nonzero []int // the pivot row is usually sparse
+	for j, v := range t.rows[r] {
+		if v.Sign() != 0 {
+			v.Mul(v, inv)
+			nonzero = append(nonzero, j)
+		}
+	}
+	var tmp big.Rat
+	eliminate := func(row []*big.Rat) {
+		if row[c].Sign() == 0 {
+			return
+		}
+		f := new(big.Rat).Set(row[c])
+		for _, j := range nonzero {
+			row[j].Sub(row[j], tmp.Mul(f, t.rows[r][j]))
+		}
+	}
+	for i, row := range t.rows {
+		if i != r {
+			eliminate(row)
+		}
+	}
+	eliminate(t.obj)
+	t.basis[r] = c
+}
+
+// optimize pivots until no column below limit has a negative reduced cost,
+// using Bland's rule so it can't cycle.  It returns false if the objective is
+// unbounded.
+func (t *tableau) optimize(limit int) bool {
+	last := len(t.obj) - 1
+	for {
+		enter := -1
+		for j := 0; j < limit; j++ {
+			if t.obj[j].Sign() < 0 {
+				enter = j
+				break
+			}
+		}
+		if enter < 0 {
+			return true
+		}
+		leave := -1
+		var best, ratio big.Rat
+		for i, row := range t.rows {
+			if row[enter].Sign() <= 0 {
+				continue
+			}
+			ratio.Quo(row[last], row[enter])
+			if c := ratio.Cmp(&best); leave < 0 || c < 0 || (c == 0 && t.basis[i] < t.basis[leave]) {
+				leave = i
+				best.Set(&ratio)
+			}
+		}
+		if leave < 0 {
+			return false
+		}
+		t.pivot(leave, enter)
+	}
+}
+
+// dualSimplex pivots an optimal tableau which has become infeasible, because
+// a constraint was added, until no basic variable is negative, keeping every
+// reduced cost non-negative.  Like optimize it breaks ties by the smallest
+// index so it can't cycle.  It returns false if the constraints are
+// infeasible.
+func (t *tableau) dualSimplex() bool {
+	last := len(t.obj) - 1
+	for {
+		leave := -1
+		for i, row := range t.rows {
+			if row[last].Sign() < 0 && (leave < 0 || t.basis[i] < t.basis[leave]) {
+				leave = i
+			}
+		}
+		if leave < 0 {
+			return true
+		}
+		enter := -1
+		var best, ratio big.Rat
+		for j, v := range t.rows[leave][:last] {
+			if v.Sign() >= 0 {
+				continue
+			}
+			ratio.Quo(t.obj[j], v)
+			ratio.Neg(&ratio)
+			if enter < 0 || ratio.Cmp(&best) < 0 {
+				enter = j
+				best.Set(&ratio)
+			}
+		}
+		if enter < 0 {
+			return false
+		}
+		t.pivot(leave, enter)
+	}
+}
+
+// dropArtificial removes the artificial columns once phase 1 has found a
+// feasible basis, along with rows whose basic variable is still artificial,
+// which are redundant.
+func (t *tableau) dropArtificial() {
+	last := len(t.rows[0]) - 1
+	var rows [][]*big.Rat
+	var basis []int
+	for i, row := range t.rows {
+		if t.basis[i] < t.artificial {
+			rows = append(rows, append(row[:t.artificial:t.artificial], row[last]))
+			basis = append(basis, t.basis[i])
+		}
+	}
+	t.rows, t.basis = rows, basis
+}
+
+// addBound adds x[j] <= v if sense is -1 or x[j] >= v if sense is 1 to an
+// optimal tableau without artificial columns, then restores optimality with
+// the dual simplex method rather than starting over.  The first bound in each
+// direction adds a row with a new slack column.  Later ones change that row's
+// right hand side, which shifts every row's right hand side by the change
+// times the slack's column, so the tableau doesn't grow as branches get
+// deeper.  It returns false if the constraints become infeasible.
+func (t *tableau) addBound(j, sense int, v *big.Rat) bool {
+	last := len(t.obj) - 1
+	bounds := &t.upper
+	if sense > 0 {
+		bounds = &t.lower
+	}
+	if b, ok := (*bounds)[j]; ok {
+		// The row is x[j] + s = v or -x[j] + s = -v.
+		delta := new(big.Rat).Sub(v, b.value)
+		if sense > 0 {
+			delta.Neg(delta)
+		}
+		var tmp big.Rat
+		for _, row := range t.rows {
+			row[last].Add(row[last], tmp.Mul(delta, row[b.slack]))
+		}
+		t.obj[last].Add(t.obj[last], tmp.Mul(delta, t.obj[b.slack]))
+		(*bounds)[j] = bound{slack: b.slack, value: v}
+		return t.dualSimplex()
+	}
+	widen := func(row []*big.Rat) []*big.Rat {
+		row = append(row, row[last])
+		row[last] = new(big.Rat)
+		return row
+	}
+	for i := range t.rows {
+		t.rows[i] = widen(t.rows[i])
+	}
+	t.obj = widen(t.obj)
+	t.artificial++
+	row := make([]*big.Rat, last+2)
+	for k := range row {
+		row[k] = new(big.Rat)
+	}
+	row[j].SetInt64(int64(-sense))
+	row[last].SetInt64(1)
+	row[last+1].Mul(v, row[j])
+	// Express the new row in terms of the non-basic columns.
+	var tmp big.Rat
+	if i := slices.Index(t.basis, j); i >= 0 {
+		f := new(big.Rat).Set(row[j])
+		for k, x := range t.rows[i] {
+			row[k].Sub(row[k], tmp.Mul(f, x))
+		}
+	}
+	t.rows = append(t.rows, row)
+	t.basis = append(t.basis, last)
+	if *bounds == nil {
+		*bounds = make(map[int]bound)
+	}
+	(*bounds)[j] = bound{slack: last, value: v}
+	return t.dualSimplex()
+}
+
+// solution returns the values of the first n variables and the objective.
+func (t *tableau) solution(n int) ([]*big.Rat, *big.Rat) {
+	last := len(t.obj) - 1
+	x := make([]*big.Rat, n)
+	for j := range x {
+		x[j] = new(big.Rat)
+	}
+	for i, b := range t.basis {
+		if b < n {
+			x[b].Set(t.rows[i][last])
+		}
+	}
+	return x, new(big.Rat).Neg(t.obj[last])
+}
+
+// solveLP minimizes cost·x subject to cons and x >= 0 with the two-phase
+// simplex method.  It returns the optimal tableau, with the artificial columns
+// removed so more constraints can be added, or false if the constraints are
+// infeasible.
+func solveLP(cost []*big.Rat, cons []constraint) (*tableau, bool) {
+	n := len(cost)
+	t := newTableau(n, cons)
+	last := len(t.rows[0]) - 1
+	// Phase 1: minimize the sum of artificial variables.
+	phase1 := make([]*big.Rat, last)
+	for j := t.artificial; j < last; j++ {
+		phase1[j] = big.NewRat(1, 1)
+	}
+	for j := 0; j < t.artificial; j++ {
+		phase1[j] = ratZero
+	}
+	t.setObjective(phase1)
+	t.optimize(last)
+	if t.obj[last].Sign() != 0 {
+		return nil, false
+	}
+	// Drive artificial variables at zero out of the basis where possible; any
+	// left are in redundant rows.
+	for i, b := range t.basis {
+		if b < t.artificial {
+			continue
+		}
+		for j := 0; j < t.artificial; j++ {
+			if t.rows[i][j].Sign() != 0 {
+				t.pivot(i, j)
+				break
+			}
+		}
+	}
+	t.dropArtificial()
+	// Phase 2: minimize cost.
+	t.setObjective(cost)
+	if !t.optimize(t.artificial) {
+		log.Fatalf("Unbounded linear program %v", cons)
+	}
+	return t, true
+}
+
+// ratCeil returns the smallest integer at least r.
+func ratCeil(r *big.Rat) *big.Int {
+	q, m := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	if m.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return q
+}
+
+// ratFloor returns the largest integer at most r.
+func ratFloor(r *big.Rat) *big.Int {
+	q, _ := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	return q
+}
+
+// mostFractional returns the index of the value in x whose fractional part is
+// closest to 1/2, or -1 if they're all integers.
+func mostFractional(x []*big.Rat) int {
+	res := -1
+	half := big.NewRat(1, 2)
+	var best, dist big.Rat
+	for j, v := range x {
+		if v.IsInt() {
+			continue
+		}
+		dist.Sub(v, new(big.Rat).SetInt(ratFloor(v)))
+		dist.Sub(&dist, half)
+		dist.Abs(&dist)
+		if res < 0 || dist.Cmp(&best) < 0 {
+			res = j
+			best.Set(&dist)
+		}
+	}
+	return res
+}
+
+// solveILP minimizes cost·x subject to cons, x >= 0, and x integer, with
+// depth-first branch and bound: solve the linear relaxation, and if some x[j]
+// is fractional v, add x[j] <= floor(v) or x[j] >= ceil(v) to copies of the
+// optimal tableau and re-optimize each with the dual simplex method.  The
+// most fractional x[j] is branched on, and the child with the better
+// relaxation is searched first.  Branches whose relaxation can't beat the best
+// integer solution so far are pruned.  If round is not nil, it's given the
+// root relaxation and may return an integer solution to start pruning with.
+// cost must have integer coefficients.  It returns false if there is no
+// integer solution.
+func solveILP(cost []*big.Rat, cons []constraint, round func(x []*big.Rat) []*big.Int) ([]*big.Int, bool) {
+	n := len(cost)
+	root, ok := solveLP(cost, cons)
+	if !ok {
+		return nil, false
+	}
+	var best []*big.Int
+	var bestObj *big.Int
+	if round != nil {
+		x, _ := root.solution(n)
+		if best = round(x); best != nil {
+			bestObj = new(big.Int)
+			var tmp big.Int
+			for j, v := range best {
+				bestObj.Add(bestObj, tmp.Mul(cost[j].Num(), v))
+			}
+		}
+	}
+	var branch func(t *tableau)
+	branch = func(t *tableau) {
+		x, obj := t.solution(n)
+		if bestObj != nil && ratCeil(obj).Cmp(bestObj) >= 0 {
+			return
+		}
+		frac := mostFractional(x)
+		if frac < 0 {
+			best = make([]*big.Int, len(x))
+			for j, v := range x {
+				best[j] = new(big.Int).Set(v.Num())
+			}
+			bestObj = ratCeil(obj)
+			return
+		}
+		var children []*tableau
+		down, up := t.clone(), t
+		if down.addBound(frac, -1, new(big.Rat).SetInt(ratFloor(x[frac]))) {
+			children = append(children, down)
+		}
+		if up.addBound(frac, 1, new(big.Rat).SetInt(ratCeil(x[frac]))) {
+			children = append(children, up)
+		}
+		last := func(t *tableau) *big.Rat { return t.obj[len(t.obj)-1] }
+		slices.SortStableFunc(children, func(a, b *tableau) int { return last(b).Cmp(last(a)) })
+		for _, c := range children {
+			branch(c)
+		}
+	}
+	branch(root)
+	return best, best != nil
+}
+
+// roundPresses turns fractional presses x into a way to reach m's joltage
+// levels, if it can: it rounds each number of presses down, then repeatedly
+// presses whichever button increases the most levels which are still short
+// without overshooting any.  It returns nil if that gets stuck.
+func roundPresses(m machine, x []*big.Rat) []*big.Int {
+	short := slices.Clone(m.joltage)
+	presses := make([]int, len(m.buttons))
+	for j, v := range x {
+		presses[j] = int(ratFloor(v).Int64())
+		for i := range short {
+			if m.buttons[j]&(1<<i) != 0 {
+				short[i] -= presses[j]
+			}
+		}
+	}
+	for slices.ContainsFunc(short, func(s int) bool { return s != 0 }) {
+		press, most := -1, 0
+		for j, b := range m.buttons {
+			count := 0
+			for i, s := range short {
+				if b&(1<<i) == 0 {
+					continue
+				}
+				if s <= 0 {
+					count = -1
+					break
+				}
+				count++
+			}
+			if count > most {
+				press, most = j, count
+			}
+		}
+		if press < 0 {
+			return nil
+		}
+		presses[press]++
+		for i := range short {
+			if m.buttons[press]&(1<<i) != 0 {
+				short[i]--
+			}
+		}
+	}
+	res := make([]*big.Int, len(presses))
+	for j, p := range presses {
+		res[j] = big.NewInt(int64(p))
+	}
+	return res
+}
+
+// machineILP returns the fewest presses to reach x's joltage levels, solving
+// the integer linear program exactly.
+func machineILP(x machine) int {
+	cost := make([]*big.Rat, len(x.buttons))
+	for j := range cost {
+		cost[j] = big.NewRat(1, 1)
+	}
+	cons := make([]constraint, len(x.joltage))
+	for i, v := range x.joltage {
+		coeffs := make([]*big.Rat, len(x.buttons))
+		for j, b := range x.buttons {
+			coeffs[j] = big.NewRat(int64((b>>i)&1), 1)
+		}
+		cons[i] = constraint{coeffs: coeffs, rhs: big.NewRat(int64(v), 1)}
+	}
+	presses, ok := solveILP(cost, cons, func(p []*big.Rat) []*big.Int { return roundPresses(x, p) })
+	if !ok {
+		log.Fatalf("%d: no way to reach joltage %v", x.num, x.joltage)
+	}
+	// Double-check the solution with plain integers.
+	got := make([]int, len(x.joltage))
+	total := 0
+	for j, p := range presses {
+		n := int(p.Int64())
+		total += n
+		for i := range got {
+			if x.buttons[j]&(1<<i) != 0 {
+				got[i] += n
+			}
+		}
+	}
+	for i := range got {
+		if got[i] != x.joltage[i] {
+			log.Fatalf("%d: presses %v give joltage %v, want %v", x.num, presses, got, x.joltage)
+		}
+	}
+	return total
+}
+
 func absInt(i int) int {
 	if i < 0 {
 		return -1 * i
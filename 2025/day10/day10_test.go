@@ -0,0 +1,54 @@
+// Copyright 2025 Trevor Stone
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file or at
+// https://opensource.org/licenses/MIT.
+
+// Run with
+// % go test day10_test.go day10.go runner.go
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestILPSlowMachines(t *testing.T) {
+	lines, err := readLines("input.slow.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := strconv.Atoi(readExpected("input.slow.txt")[1])
+	if err != nil {
+		t.Fatalf("input.slow.expected: %v", err)
+	}
+	sum := 0
+	for i, l := range lines {
+		m := parseMachine(l)
+		m.num = i + 1
+		sum += machineILP(m)
+	}
+	if sum != want {
+		t.Errorf("got %d presses, want %d", sum, want)
+	}
+}
+
+// BenchmarkILPSlowMachines times machineILP on input.slow.txt; the first
+// branch and bound version, which solved each relaxation from scratch, took
+// up to 30 seconds on some of these machines.
+func BenchmarkILPSlowMachines(b *testing.B) {
+	lines, err := readLines("input.slow.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	machines := make([]machine, len(lines))
+	for i, l := range lines {
+		machines[i] = parseMachine(l)
+		machines[i].num = i + 1
+	}
+	for b.Loop() {
+		for _, m := range machines {
+			machineILP(m)
+		}
+	}
+}
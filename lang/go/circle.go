@@ -0,0 +1,171 @@
+// Copyright 2025 Trevor Stone
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file or at
+// https://opensource.org/licenses/MIT.
+
+// circle.go provides Circle, a circular doubly-linked sequence of ints which
+// remembers the order its values were added, for puzzles which shuffle numbers
+// around a ring like 2022 day 20 (mixing) and 2020 day 23 (crab cups).
+// Symlink to this file from the directory with the solution, next to runner.go.
+
+package main
+
+import "log"
+
+// CircleNode is one value in a Circle.  id is the value's position in the
+// original sequence, which doesn't change as the node moves.
+type CircleNode struct {
+	Value      int
+	id         int
+	prev, next *CircleNode
+}
+
+// Circle is a ring of nodes, each of which can be moved around the ring in
+// constant time plus the time to walk to its destination.
+type Circle struct {
+	nodes   []*CircleNode
+	byValue map[int]*CircleNode
+}
+
+// NewCircle returns a circle of each of ints times multiplier, in order.
+func NewCircle(ints []int, multiplier int) *Circle {
+	if len(ints) == 0 {
+		log.Fatalf("Cannot make an empty circle")
+	}
+	c := &Circle{nodes: make([]*CircleNode, len(ints)), byValue: make(map[int]*CircleNode, len(ints))}
+	for i, v := range ints {
+		n := &CircleNode{Value: v * multiplier, id: i}
+		c.nodes[i] = n
+		if _, ok := c.byValue[n.Value]; !ok {
+			c.byValue[n.Value] = n
+		}
+		if i > 0 {
+			n.prev = c.nodes[i-1]
+			n.prev.next = n
+		}
+	}
+	c.nodes[0].prev = c.nodes[len(ints)-1]
+	c.nodes[len(ints)-1].next = c.nodes[0]
+	return c
+}
+
+// Len returns the number of values in the circle.
+func (c *Circle) Len() int { return len(c.nodes) }
+
+// Node returns the node which was at position i in the original sequence.
+func (c *Circle) Node(i int) *CircleNode { return c.nodes[i] }
+
+// Find returns the node with value v, or the earliest one in the original
+// sequence if v appears more than once, or nil if v isn't in the circle.
+func (c *Circle) Find(v int) *CircleNode { return c.byValue[v] }
+
+// Mix moves each node in original order, rounds times.  steps returns the
+// number of other nodes to pass given a node's value and the circle's size.
+func (c *Circle) Mix(rounds int, steps func(value, size int) int) {
+	for r := 0; r < rounds; r++ {
+		for _, n := range c.nodes {
+			n.Move(steps(n.Value, len(c.nodes)))
+		}
+	}
+}
+
+// Sample returns the values at each offset after the node with value from,
+// wrapping around the circle.  Negative offsets count backwards.
+func (c *Circle) Sample(from int, offsets []int) []int {
+	start := c.Find(from)
+	if start == nil {
+		log.Fatalf("No %d in circle", from)
+	}
+	res := make([]int, len(offsets))
+	for i, o := range offsets {
+		res[i] = start.Step(o % len(c.nodes)).Value
+	}
+	return res
+}
+
+// SliceFrom returns a snapshot of the circle's values in their current order,
+// starting with the node with value v.
+func (c *Circle) SliceFrom(v int) []int {
+	n := c.Find(v)
+	if n == nil {
+		log.Fatalf("No %d in circle", v)
+	}
+	return n.toSlice(len(c.nodes))
+}
+
+// Equal returns true if both circles have the original sequence's nodes in
+// the same circular order, regardless of where they started.
+func (c *Circle) Equal(o *Circle) bool {
+	if len(c.nodes) != len(o.nodes) {
+		return false
+	}
+	a, b := c.nodes[0], o.nodes[0]
+	for range c.nodes {
+		if a.id != b.id {
+			return false
+		}
+		a, b = a.next, b.next
+	}
+	return true
+}
+
+// Next returns the node after n.
+func (n *CircleNode) Next() *CircleNode { return n.next }
+
+// Prev returns the node before n.
+func (n *CircleNode) Prev() *CircleNode { return n.prev }
+
+// Step returns the node steps places after n, or before if steps is negative.
+func (n *CircleNode) Step(steps int) *CircleNode {
+	o := n
+	for ; steps < 0; steps++ {
+		o = o.prev
+	}
+	for ; steps > 0; steps-- {
+		o = o.next
+	}
+	return o
+}
+
+// Move removes n from the circle and reinserts it after passing steps other
+// nodes, forwards if positive and backwards if negative.
+func (n *CircleNode) Move(steps int) {
+	if steps == 0 {
+		return
+	}
+	// Remove the current node from the circle first, so it doesn't count itself.
+	prev := n.prev
+	prev.next = n.next
+	n.next.prev = prev
+	n.prev, n.next = n, n
+	prev.Step(steps).InsertAfter(n)
+}
+
+// Cut removes the count nodes after n from the circle and returns the first
+// of them, linked to each other in a ring, for reinsertion with InsertAfter.
+func (n *CircleNode) Cut(count int) *CircleNode {
+	first := n.next
+	last := n.Step(count)
+	n.next = last.next
+	last.next.prev = n
+	first.prev, last.next = last, first
+	return first
+}
+
+// InsertAfter splices the ring of nodes returned by Cut, or a single node
+// removed by Move, after n.
+func (n *CircleNode) InsertAfter(first *CircleNode) {
+	last, next := first.prev, n.next
+	n.next, first.prev = first, n
+	last.next, next.prev = next, last
+}
+
+func (n *CircleNode) toSlice(size int) []int {
+	ints := make([]int, 0, size)
+	ints = append(ints, n.Value)
+	for o := n.next; o != n; o = o.next {
+		ints = append(ints, o.Value)
+	}
+	return ints
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 Trevor Stone
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file or at
+// https://opensource.org/licenses/MIT.
+
+// intset.go provides Interner, which maps names to small dense ints, and
+// Bitset, a set of such ints, for puzzles which would otherwise build lots of
+// map[string]bool sets and sort and join them to deduplicate.
+// Symlink to this file from the directory with the solution, next to runner.go.
+
+package main
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"slices"
+)
+
+// Interner assigns IDs 0, 1, 2, ... to values in the order they're first seen.
+type Interner[T comparable] struct {
+	ids    map[T]int
+	values []T
+}
+
+func NewInterner[T comparable]() *Interner[T] {
+	return &Interner[T]{ids: make(map[T]int)}
+}
+
+// ID returns v's ID, assigning the next one if v hasn't been seen before.
+func (in *Interner[T]) ID(v T) int {
+	if id, ok := in.ids[v]; ok {
+		return id
+	}
+	in.ids[v] = len(in.values)
+	in.values = append(in.values, v)
+	return len(in.values) - 1
+}
+
+// Lookup returns v's ID and true, or false if v hasn't been seen.
+func (in *Interner[T]) Lookup(v T) (int, bool) {
+	id, ok := in.ids[v]
+	return id, ok
+}
+
+// Value returns the value with ID id.
+func (in *Interner[T]) Value(id int) T { return in.values[id] }
+
+// Len returns the number of IDs assigned.
+func (in *Interner[T]) Len() int { return len(in.values) }
+
+// Bitset is a set of non-negative ints less than the size it was made with.
+// Methods which combine two sets expect them to be the same size.
+type Bitset []uint64
+
+func NewBitset(size int) Bitset { return make(Bitset, (size+63)/64) }
+
+func (b Bitset) Set(i int)      { b[i/64] |= 1 << (i % 64) }
+func (b Bitset) Clear(i int)    { b[i/64] &^= 1 << (i % 64) }
+func (b Bitset) Has(i int) bool { return b[i/64]&(1<<(i%64)) != 0 }
+func (b Bitset) Clone() Bitset  { return slices.Clone(b) }
+
+func (b Bitset) IsEmpty() bool {
+	return !slices.ContainsFunc(b, func(w uint64) bool { return w != 0 })
+}
+
+// Count returns the number of members.
+func (b Bitset) Count() int {
+	c := 0
+	for _, w := range b {
+		c += bits.OnesCount64(w)
+	}
+	return c
+}
+
+func (b Bitset) Intersect(o Bitset) Bitset {
+	r := make(Bitset, len(b))
+	for i := range b {
+		r[i] = b[i] & o[i]
+	}
+	return r
+}
+
+func (b Bitset) Union(o Bitset) Bitset {
+	r := make(Bitset, len(b))
+	for i := range b {
+		r[i] = b[i] | o[i]
+	}
+	return r
+}
+
+// Minus returns the members of b which aren't in o.
+func (b Bitset) Minus(o Bitset) Bitset {
+	r := make(Bitset, len(b))
+	for i := range b {
+		r[i] = b[i] &^ o[i]
+	}
+	return r
+}
+
+// IntersectCount returns the size of the intersection without allocating.
+func (b Bitset) IntersectCount(o Bitset) int {
+	c := 0
+	for i := range b {
+		c += bits.OnesCount64(b[i] & o[i])
+	}
+	return c
+}
+
+// Each calls f with each member in ascending order.
+func (b Bitset) Each(f func(i int)) {
+	for wi, w := range b {
+		for w != 0 {
+			f(wi*64 + bits.TrailingZeros64(w))
+			w &= w - 1
+		}
+	}
+}
+
+// Members returns the members in ascending order.
+func (b Bitset) Members() []int {
+	res := make([]int, 0, b.Count())
+	b.Each(func(i int) { res = append(res, i) })
+	return res
+}
+
+// Key returns a string which is equal for equal sets, for use as a map key.
+func (b Bitset) Key() string {
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	buf := make([]byte, 0, len(b)*8)
+	for _, w := range b {
+		buf = binary.LittleEndian.AppendUint64(buf, w)
+	}
+	return string(buf)
+}